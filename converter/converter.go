@@ -3,35 +3,36 @@ package converter
 import (
 	"bytes"
 	"io"
-	"regexp"
 
 	"github.com/rs/zerolog"
 )
 
 type chanReader struct {
-	c      chan []byte     //We will receive data on this channel after stripping comments
-	excess *bytes.Buffer   //We will store excess bytes here and write them when space is available
-	logger *zerolog.Logger //console logger
+	c       chan []byte     //We will receive data on this channel after stripping comments
+	excess  *bytes.Buffer   //We will store excess bytes here and write them when space is available
+	logger  *zerolog.Logger //console logger
+	tracker *Tracker        //maps the offsets of the converted data back to the input.
 }
 
-var (
-	singleLineComment = regexp.MustCompile(`//(.*)`)
-	multiLineComment  = regexp.MustCompile(`/\*[\s\S]*?\*/`)
-)
-
-var stopByte = []byte("}")
-
 // New take an reader and returns another reader. Send 0 to create default size buffer. The new reader will receive data after removal of single line and multiline comments.
-func New(inp io.Reader, sizeInBytes int, logger *zerolog.Logger) io.Reader {
+// For the JSON5 dialect the data is converted to JSON as well.
+func New(inp io.Reader, sizeInBytes int, dialect string, logger *zerolog.Logger) io.Reader {
+	r, _ := NewTracked(inp, sizeInBytes, dialect, logger)
+	return r
+}
+
+// NewTracked is New, the tracker maps the offsets of the returned reader back to the input.
+func NewTracked(inp io.Reader, sizeInBytes int, dialect string, logger *zerolog.Logger) (io.Reader, *Tracker) {
 	cw := &chanReader{
-		c:      make(chan []byte, 50),
-		excess: bytes.NewBuffer(nil),
-		logger: logger,
+		c:       make(chan []byte, 50),
+		excess:  bytes.NewBuffer(nil),
+		logger:  logger,
+		tracker: newTracker(),
 	}
 
-	go cw.startParsingInput(inp, sizeInBytes)
+	go cw.startParsingInput(inp, sizeInBytes, newLexer(dialect))
 
-	return cw
+	return cw, cw.tracker
 }
 
 func (cw *chanReader) Read(buf []byte) (int, error) {
@@ -40,7 +41,7 @@ func (cw *chanReader) Read(buf []byte) (int, error) {
 
 	//first copy excess bytes from previous operation
 	n, err := cw.excess.Read(buf)
-	//Ignore EOF here as we would get false EOF in middle when our regex parsing is slow.
+	//Ignore EOF here as we would get false EOF in middle when stripping the comments is slow.
 	if err != nil && err != io.EOF {
 		cw.logger.Fatal().Err(err).Msg("error while writing to buffer")
 	}
@@ -71,76 +72,65 @@ func (cw *chanReader) Read(buf []byte) (int, error) {
 	return retn, nil
 }
 
-func (cw *chanReader) startParsingInput(inp io.Reader, sizeInBytes int) {
+func (cw *chanReader) startParsingInput(inp io.Reader, sizeInBytes int, lex lexer) {
 	if sizeInBytes <= 0 {
 		sizeInBytes = 1 << 12 //default bytes = 4kb
 	}
-	//Create a buffer to write data.
+
 	//The data we read from the input file will be written in this buffer.
-	buf := bytes.NewBuffer(make([]byte, 0, sizeInBytes))
+	buf := make([]byte, sizeInBytes)
+
+	var in, out int64 //bytes read and written till now.
+	delta := int64(0) //in - out at the last anchor.
+
+	//The lexer keeps its state between chunks. A comment or a string which is split across two chunks
+	//is continued on the next chunk, so the chunks can end anywhere and we never need to read till a closing brace.
 	for {
-		err := cw.readFromInp(inp, buf, sizeInBytes) //Write data to our buffer.
-		if err == io.EOF {                           //If we reach end of file break the loop.
+		n, err := inp.Read(buf) //Read file into buf.
+		if n > 0 {
+			//convert will remove the single line and multi line comments and return the new bytes.
+			finalBytes := make([]byte, 0, n)
+			anchors := []anchor{}
+
+			if p, ok := lex.(plain); ok && p.plain(buf[:n]) {
+				finalBytes = lex.convert(finalBytes, buf[:n])
+				if in-out != delta {
+					delta = in - out
+					anchors = append(anchors, anchor{out: out, in: in})
+				}
+			} else {
+				for i := 0; i < n; i++ { //convert byte by byte to know which input byte every output byte comes from.
+					at := out + int64(len(finalBytes))
+					if pos := in + int64(i); pos-at != delta {
+						delta = pos - at
+						anchors = append(anchors, anchor{out: at, in: pos})
+					}
+					finalBytes = lex.convert(finalBytes, buf[i:i+1])
+				}
+			}
+
+			cw.tracker.add(buf[:n], anchors)
+			in += int64(n)
+			out += int64(len(finalBytes))
+
+			if len(finalBytes) > 0 {
+				//once we get the filtered data, we push the data to the channel.
+				cw.c <- finalBytes
+			}
+		}
+
+		if err == io.EOF { //If we reach end of file break the loop.
 			break
+		} else if err != nil {
+			cw.logger.Fatal().Err(err).Msg("converter : error while reading input")
 		}
+	}
 
-		//runRegex will remove the single line and multi line comments and return the new bytes.
-		finalBytes := runRegex(buf.Bytes())
-		//once we get the filtered data, we push the data to the channel.
+	//write the slash held back by the lexer, if the input ended with one.
+	if finalBytes := lex.flush(nil); len(finalBytes) > 0 {
 		cw.c <- finalBytes
-		//We reset the buffer now so we can reuse the allocations on next read.
-		buf.Reset()
 	}
 
-	//repeat steps here for remaining bytes.
-	finalBytes := runRegex(buf.Bytes())
-	cw.c <- finalBytes
-	buf.Reset()
 	//Close the channel here so that the read method can return EOF.
 	close(cw.c)
 }
-
-func runRegex(b []byte) []byte {
-	b = singleLineComment.ReplaceAll(b, nil)
-	b = multiLineComment.ReplaceAll(b, nil)
-	return b
-}
-
-func (cw *chanReader) readFromInp(inp io.Reader, buf *bytes.Buffer, sizeInBytes int) error {
-
-	b := make([]byte, sizeInBytes)
-
-	n, err := inp.Read(b) //Read file into b.
-	if err == io.EOF {    //return if we reach EOF.
-		return err
-	} else if err != nil {
-		cw.logger.Fatal().Err(err).Msg("converter : error while reading input")
-	}
-
-	b = b[:n]    //Reslice as its possible we have got partial read.
-	buf.Write(b) //Write to buffer.
-
-	//Our saftey check. We need this check as its possible that the end of buffer may be a partial comment match.
-	//For example, lets say we have a comment [//This is a single line comment]
-	//Its possible that Read method read it partially. [//This is a sing]
-	//since we have read it till here our regex match will remove the string till sing.
-	//Because of this next read will start from [le line comment] which is not a valid json and our json decoder will fail.
-	//To avoid this, We will again read till a closing braces which signifies object closing.
-	//NOTE :: This logic will not work in case the comments itself has json strings or there are nested json objects.
-	//Have some idea or better logic? create a pull request or comment.
-	for {
-		sb := make([]byte, 1)
-		n, err := inp.Read(sb)
-		if err == io.EOF {
-			return err
-		}
-
-		buf.Write(sb[:n])
-
-		if bytes.Equal(sb, stopByte) {
-			break //break if we find closing bracket
-		}
-	}
-
-	return nil
-}
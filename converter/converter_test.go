@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/rs/zerolog"
@@ -18,7 +20,7 @@ func TestCopy(t *testing.T) {
 
 	r := bufio.NewReader(bytes.NewReader(dta))
 
-	cc := New(r, 0, &zerolog.Logger{})
+	cc := New(r, 0, JSON, &zerolog.Logger{})
 	data, err := io.ReadAll(cc)
 	if err != nil {
 		t.Error(err)
@@ -29,3 +31,128 @@ func TestCopy(t *testing.T) {
 		t.Errorf("data does not match")
 	}
 }
+
+func TestStripComments(t *testing.T) {
+
+	tests := []struct {
+		in, want string
+	}{
+		{`{"a":1} //comment`, `{"a":1} `},
+		{"//comment\n{\"a\":1}", "\n{\"a\":1}"},
+		{`{"a":/* multi
+		line */1}`, `{"a": 1}`},
+		{`{"url":"https://example.com"}`, `{"url":"https://example.com"}`},
+		{`{"a":"/* not a comment */"}`, `{"a":"/* not a comment */"}`},
+		{`{"a":"quote \" // still string"} // comment`, `{"a":"quote \" // still string"} `},
+		{`{"a":"\\"} // comment`, `{"a":"\\"} `},
+		{`{"a":1} /** stars **/`, `{"a":1}  `},
+		{`{"a":1}/`, `{"a":1}/`},
+	}
+
+	for _, tt := range tests {
+		got := string(convertAll([]byte(tt.in), JSON))
+		if got != tt.want {
+			t.Errorf("input %q : expected %q, got %q", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestStreamingChunks(t *testing.T) {
+
+	dta, err := os.ReadFile("../test-files/object_fail.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dta = append(dta, []byte(`{"url":"https://example.com/}","s":"a /* b */ c"} // trailing }`)...)
+	want := convertAll(dta, JSON)
+
+	for _, size := range []int{1, 2, 3, 7, 64, 0} { //chunks of every size should give the same result.
+		data, err := io.ReadAll(New(bytes.NewReader(dta), size, JSON, &zerolog.Logger{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(want, data) {
+			t.Errorf("chunk size %d : output does not match", size)
+		}
+	}
+}
+
+func TestJSON5(t *testing.T) {
+
+	tests := []struct {
+		in, want string
+	}{
+		{`{a:1, b_2:'x',}`, `{"a":1, "b_2":"x"}`},
+		{`[1,2,3,]`, `[1,2,3]`},
+		{"[1, // one\n 2, /* two */ ]", "[1, \n 2   ]"},
+		{`{'q':'it\'s "quoted"'}`, `{"q":"it's \"quoted\""}`},
+		{`{"esc":'\x41\v\0\a'}`, `{"esc":"\u0041\u000b\u0000a"}`},
+		{"{s:'line \\\ncontinued'}", `{"s":"line continued"}`},
+		{`{h:0xFF, n:-0x10, p:+1, d:.5, t:5., e:1.e3}`, `{"h":255, "n":-16, "p":1, "d":0.5, "t":5, "e":1e3}`},
+		{`{i:Infinity, m:-Infinity, n:NaN, b:true, z:null}`, `{"i":"Infinity", "m":"-Infinity", "n":"NaN", "b":true, "z":null}`},
+		{`{url:'https://example.com'}`, `{"url":"https://example.com"}`},
+		{`[{null: 1, true: 2}, {a: [null, {false: false}], Infinity: 1}]`, `[{"null": 1, "true": 2}, {"a": [null, {"false": false}], "Infinity": 1}]`}, //keys named like literals are quoted.
+	}
+
+	for _, tt := range tests {
+		got := string(convertAll([]byte(tt.in), JSON5))
+		if got != tt.want {
+			t.Errorf("input %q : expected %q, got %q", tt.in, tt.want, got)
+		}
+
+		for _, size := range []int{1, 3} { //the same result when the input is split in small chunks.
+			data, _ := io.ReadAll(New(bytes.NewReader([]byte(tt.in)), size, JSON5, &zerolog.Logger{}))
+			if string(data) != tt.want {
+				t.Errorf("input %q chunk size %d : expected %q, got %q", tt.in, size, tt.want, data)
+			}
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+
+	tests := []struct {
+		in, dialect, want string
+	}{
+		{"  // comment\n [{\"a\":1}]", JSON, Array},
+		{"/* [ */ {\"a\":1}", JSON, Objects},
+		{"{\"a\":1}\n{\"a\":2}\n", JSON, Lines},
+		{"{\"a\":1}", JSON, Lines},
+		{"{\"a\":\n1}\n{\"a\":2}", JSON, Objects},
+		{"{\"a\":1} {\"a\":2}", JSON, Objects},
+		{"{\"a\":1}\n{\"a\":2} // comment", JSON, Objects},
+		{"{a:1}\n{a:2}", JSON5, Objects},
+		{"[{a:1,},]", JSON5, Array},
+	}
+
+	for _, tt := range tests {
+		got := Detect(bufio.NewReader(bytes.NewReader([]byte(tt.in))), tt.dialect)
+		if got != tt.want {
+			t.Errorf("input %q : expected %s, got %s", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestLocate(t *testing.T) {
+
+	dta := "// header\n/* a\n b */ {\"a\": 1,\n  /* c */ \"b\": x}\n"
+
+	for _, size := range []int{1, 5, 0} {
+		r, tracker := NewTracked(bytes.NewReader([]byte(dta)), size, JSON, &zerolog.Logger{})
+		out, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loc := tracker.Locate(int64(bytes.IndexByte(out, 'x')))
+		if loc.Offset != int64(strings.IndexByte(dta, 'x')) || loc.Line != 4 || loc.Column != 16 {
+			t.Errorf("chunk size %d : expected line 4 column 16, got %+v", size, loc)
+		}
+
+		if want := "  /* c */ \"b\": x}\n               ^"; loc.Snippet != want {
+			t.Errorf("chunk size %d : expected snippet %q, got %q", size, want, loc.Snippet)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Input modes found by Detect.
+const (
+	Array   = "array"   //a JSON array of objects.
+	Objects = "objects" //a stream of objects, can have comments and span multiple lines.
+	Lines   = "ndjson"  //newline delimited JSON, one object per line without comments.
+)
+
+const sniffSize = 1 << 16 //max bytes to look at for detecting the input mode, limited by the size of the reader.
+
+// Detect looks at the start of the input, skipping whitespace and comments, and returns the input mode.
+// Nothing is consumed from r. Inputs which are not arrays are object streams, unless every complete line
+// in the sniffed bytes is a single JSON object, in that case its newline delimited JSON.
+func Detect(r *bufio.Reader, dialect string) string {
+
+	size := sniffSize
+	if r.Size() < size {
+		size = r.Size()
+	}
+
+	b, err := r.Peek(size)
+	eof := err == io.EOF
+
+	stripped := convertAll(b, dialect)
+	trimmed := bytes.TrimSpace(stripped)
+
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return Array
+	}
+
+	//Newline delimited JSON can not have comments and must be plain JSON.
+	if dialect != JSON || len(stripped) != len(b) || len(trimmed) <= 0 {
+		return Objects
+	}
+
+	lines := bytes.Split(b, []byte("\n"))
+	if !eof { //The last line could be cut by the peek, ignore it.
+		lines = lines[:len(lines)-1]
+	}
+
+	found := false
+	for _, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) <= 0 {
+			continue
+		}
+		if line[0] != '{' || !json.Valid(line) {
+			return Objects
+		}
+		found = true
+	}
+
+	if !found { //first object does not end on its line or is longer than the sniffed bytes.
+		return Objects
+	}
+
+	return Lines
+}
@@ -0,0 +1,273 @@
+package converter
+
+import (
+	"math/big"
+	"strings"
+)
+
+// JSON5 lexer states, the comment states are shared with the JSON lexer.
+const (
+	stateWord = iota + stateBlockStar + 1 //inside an unquoted word, a key, a number or a literal like true.
+	stateHex                              //inside a \x escape of a string.
+	stateCR                               //after a backslash and carriage return inside a string, a line continuation.
+)
+
+// json5 converts JSON5 to JSON. Trailing commas are dropped, single quoted strings and unquoted keys are double quoted,
+// hex numbers are written as decimals and Infinity and NaN are written as strings. Comments are removed like in the JSON lexer.
+type json5 struct {
+	state int
+	quote byte   //quote character of the current string.
+	word  []byte //the word being read, a word can be split across chunks.
+	hex   []byte //digits of the current \x escape.
+	comma bool   //a comma was read, it is written only if the next token is not a closing bracket.
+	held  []byte //whitespace after the held back comma.
+	nest  []byte //open brackets, a comma inside an object is followed by a key.
+	key   bool   //the next token is a key.
+	isKey bool   //the word being read is a key, keys like null or true are quoted too.
+}
+
+func (l *json5) convert(dst, src []byte) []byte {
+	for _, c := range src {
+		dst = l.step(dst, c)
+	}
+	return dst
+}
+
+func (l *json5) step(dst []byte, c byte) []byte {
+
+	switch l.state {
+	case stateCode:
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			return l.space(dst, c)
+		case c == '\v' || c == '\f': //JSON5 whitespace which is not allowed in JSON.
+			return l.space(dst, ' ')
+		case c == '/':
+			l.state = stateSlash
+			return dst
+		case c == ',':
+			dst = l.writeComma(dst, c) //a comma followed by a comma is invalid anyways, let the decoder report it.
+			l.comma = true
+			l.key = len(l.nest) > 0 && l.nest[len(l.nest)-1] == '{'
+			return dst
+		}
+
+		dst = l.writeComma(dst, c)
+
+		key := l.key
+		l.key = false
+
+		switch {
+		case c == '"' || c == '\'':
+			l.quote = c
+			l.state = stateString
+			return append(dst, '"')
+		case isWordByte(c):
+			l.word = append(l.word[:0], c)
+			l.isKey = key
+			l.state = stateWord
+			return dst
+		case c == '{' || c == '[':
+			l.nest = append(l.nest, c)
+			l.key = c == '{'
+		case (c == '}' || c == ']') && len(l.nest) > 0:
+			l.nest = l.nest[:len(l.nest)-1]
+		}
+		return append(dst, c)
+
+	case stateWord:
+		if isWordByte(c) {
+			l.word = append(l.word, c)
+			return dst
+		}
+		l.state = stateCode
+		dst = append(dst, convertWord(l.word, l.isKey)...)
+		return l.step(dst, c)
+
+	case stateString:
+		switch {
+		case c == '\\':
+			l.state = stateEscape
+		case c == l.quote:
+			l.state = stateCode
+			dst = append(dst, '"')
+		case c == '"': //double quote inside a single quoted string.
+			dst = append(dst, '\\', '"')
+		default:
+			dst = append(dst, c)
+		}
+		return dst
+
+	case stateEscape:
+		l.state = stateString
+		switch c {
+		case '\'':
+			return append(dst, '\'')
+		case '\n': //line continuation, the escaped new line is not part of the string.
+			return dst
+		case '\r':
+			l.state = stateCR
+			return dst
+		case 'x':
+			l.hex = l.hex[:0]
+			l.state = stateHex
+			return dst
+		case 'v':
+			return append(dst, `\u000b`...)
+		case '0':
+			return append(dst, `\u0000`...)
+		case '"', '\\', '/', 'b', 'f', 'n', 'r', 't', 'u':
+			return append(dst, '\\', c)
+		}
+		return l.step(dst, c) //any other escaped character is the character itself.
+
+	case stateHex:
+		l.hex = append(l.hex, c)
+		if len(l.hex) == 2 {
+			l.state = stateString
+			dst = append(append(dst, `\u00`...), l.hex...)
+		}
+		return dst
+
+	case stateCR:
+		l.state = stateString
+		if c == '\n' {
+			return dst
+		}
+		return l.step(dst, c)
+
+	case stateSlash:
+		switch c {
+		case '/':
+			l.state = stateLineComment
+		case '*':
+			l.state = stateBlockComment
+		default: //Not a comment, write the slash we held back. The decoder will report it as invalid JSON.
+			l.state = stateCode
+			dst = l.step(append(l.writeComma(dst, '/'), '/'), c)
+		}
+		return dst
+
+	case stateLineComment:
+		if c == '\n' {
+			l.state = stateCode
+			dst = l.space(dst, c)
+		}
+		return dst
+
+	case stateBlockComment:
+		if c == '*' {
+			l.state = stateBlockStar
+		}
+		return dst
+
+	case stateBlockStar:
+		switch c {
+		case '/':
+			l.state = stateCode
+			dst = l.space(dst, ' ')
+		case '*':
+		default:
+			l.state = stateBlockComment
+		}
+		return dst
+	}
+
+	return dst
+}
+
+// space writes the whitespace c, or holds it back after a held back comma so that it stays after the comma.
+func (l *json5) space(dst []byte, c byte) []byte {
+	if l.comma {
+		l.held = append(l.held, c)
+		return dst
+	}
+	return append(dst, c)
+}
+
+// writeComma writes the comma which was held back, unless the next token c closes an object or array.
+func (l *json5) writeComma(dst []byte, c byte) []byte {
+	if !l.comma {
+		return dst
+	}
+	l.comma = false
+	if c != '}' && c != ']' {
+		dst = append(dst, ',')
+	}
+	dst = append(dst, l.held...)
+	l.held = l.held[:0]
+	return dst
+}
+
+func (l *json5) flush(dst []byte) []byte {
+	switch l.state {
+	case stateWord:
+		dst = append(dst, convertWord(l.word, l.isKey)...)
+	case stateSlash:
+		dst = append(dst, '/')
+	}
+	l.state = stateCode
+	return l.writeComma(dst, ']') //a trailing comma at the end of the input is dropped.
+}
+
+// isWordByte reports if c can be part of an unquoted key, a number or a literal.
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '$' || c == '.' || c == '+' || c == '-' || c >= 0x80
+}
+
+// convertWord converts an unquoted word to JSON. Keys and words which are not numbers or literals are quoted.
+func convertWord(word []byte, key bool) []byte {
+
+	w := string(word)
+	if key {
+		return []byte(`"` + w + `"`)
+	}
+
+	switch w {
+	case "true", "false", "null":
+		return word
+	case "Infinity", "+Infinity", "-Infinity":
+		return []byte(`"` + strings.TrimPrefix(w, "+") + `"`)
+	case "NaN", "+NaN", "-NaN":
+		return []byte(`"NaN"`)
+	}
+
+	c := w[0]
+	if c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.' {
+		return []byte(convertNumber(w))
+	}
+
+	return []byte(`"` + w + `"`)
+}
+
+// convertNumber converts a JSON5 number to JSON. If the number is not valid it is returned as is, so that the decoder reports it.
+func convertNumber(w string) string {
+
+	sign := ""
+	switch w[0] {
+	case '-':
+		sign = "-"
+		w = w[1:]
+	case '+':
+		w = w[1:]
+	}
+
+	if strings.HasPrefix(w, "0x") || strings.HasPrefix(w, "0X") {
+		n, ok := new(big.Int).SetString(w[2:], 16)
+		if !ok {
+			return sign + w
+		}
+		return sign + n.String()
+	}
+
+	if strings.HasPrefix(w, ".") { //.5 is 0.5
+		w = "0" + w
+	}
+
+	if dot := strings.IndexByte(w, '.'); dot >= 0 && (dot == len(w)-1 || w[dot+1] == 'e' || w[dot+1] == 'E') { //5. is 5
+		w = w[:dot] + w[dot+1:]
+	}
+
+	return sign + w
+}
@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"bytes"
+)
+
+// Lexer states.
+const (
+	stateCode         = iota //outside of strings and comments.
+	stateString              //inside a string literal.
+	stateEscape              //after a backslash inside a string literal.
+	stateSlash               //after a slash outside of strings, could be the start of a comment.
+	stateLineComment         //inside a // comment.
+	stateBlockComment        //inside a /* */ comment.
+	stateBlockStar           //after a star inside a /* */ comment, could be the end of the comment.
+)
+
+// Input dialects.
+const (
+	JSON  = "json"  //JSON with single line and multi line comments.
+	JSON5 = "json5" //JSON5, https://json5.org
+)
+
+// lexer converts the input chunk by chunk. It keeps its state between chunks, so a token can be split across chunks.
+type lexer interface {
+	convert(dst, src []byte) []byte //appends the converted src to dst.
+	flush(dst []byte) []byte        //appends what was held back at the end of the input.
+}
+
+// plain is implemented by lexers which can tell that a chunk is written unchanged, so that its offsets do not need to be tracked byte by byte.
+type plain interface {
+	plain(src []byte) bool
+}
+
+// newLexer returns the lexer of the dialect.
+func newLexer(dialect string) lexer {
+	if dialect == JSON5 {
+		return &json5{}
+	}
+	return &stripper{}
+}
+
+// ValidDialect reports if the dialect is supported.
+func ValidDialect(dialect string) bool {
+	return dialect == JSON || dialect == JSON5
+}
+
+// stripper removes single line and multi line comments from JSON. It tracks string literals so that
+// comment markers inside strings, like in "https://example.com", are left untouched.
+type stripper struct {
+	state int
+}
+
+// convertAll converts the complete input b with the lexer of the dialect.
+func convertAll(b []byte, dialect string) []byte {
+	l := newLexer(dialect)
+	out := l.convert(make([]byte, 0, len(b)), b)
+	return l.flush(out)
+}
+
+// convert appends src to dst without the comments and returns the extended slice.
+func (s *stripper) convert(dst, src []byte) []byte {
+
+	for _, c := range src {
+
+		switch s.state {
+		case stateCode:
+			switch c {
+			case '"':
+				s.state = stateString
+			case '/':
+				s.state = stateSlash
+				continue //wait for the next byte to know if its a comment.
+			}
+			dst = append(dst, c)
+
+		case stateString:
+			switch c {
+			case '\\':
+				s.state = stateEscape
+			case '"':
+				s.state = stateCode
+			}
+			dst = append(dst, c)
+
+		case stateEscape:
+			s.state = stateString
+			dst = append(dst, c)
+
+		case stateSlash:
+			switch c {
+			case '/':
+				s.state = stateLineComment
+			case '*':
+				s.state = stateBlockComment
+			default: //Not a comment, write the slash we held back. The decoder will report it as invalid JSON.
+				s.state = stateCode
+				dst = s.convert(append(dst, '/'), []byte{c})
+			}
+
+		case stateLineComment:
+			if c == '\n' { //The new line is kept so that the lines of the input stay the same.
+				s.state = stateCode
+				dst = append(dst, c)
+			}
+
+		case stateBlockComment:
+			if c == '*' {
+				s.state = stateBlockStar
+			}
+
+		case stateBlockStar:
+			switch c {
+			case '/':
+				s.state = stateCode
+				dst = append(dst, ' ') //replace the comment with a space so that the tokens around it stay separated.
+			case '*':
+			default:
+				s.state = stateBlockComment
+			}
+		}
+	}
+
+	return dst
+}
+
+// plain reports if src has no comment and is written as it is. Comments start with a slash, a slash in a string is checked byte by byte as well.
+func (s *stripper) plain(src []byte) bool {
+	return (s.state == stateCode || s.state == stateString || s.state == stateEscape) && bytes.IndexByte(src, '/') < 0
+}
+
+// flush appends the slash held back at the end of the input, if any.
+func (s *stripper) flush(dst []byte) []byte {
+	if s.state == stateSlash {
+		dst = append(dst, '/')
+	}
+	s.state = stateCode
+	return dst
+}
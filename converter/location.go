@@ -0,0 +1,206 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// windowSize is the number of bytes of the input kept to show the lines of the errors.
+const windowSize = 4 << 20
+
+// maxSnippet is the max length of the line shown in the snippet, long lines are cut around the column.
+const maxSnippet = 120
+
+// Location is a position in the original input.
+type Location struct {
+	Offset  int64  //byte offset in the original input.
+	Line    int    //1 based line, 0 if the line is not known anymore.
+	Column  int    //1 based column in characters.
+	Snippet string //the line with a marker under the column.
+}
+
+// String returns the line and column, or the offset if the line is not known.
+func (l Location) String() string {
+	if l.Line <= 0 {
+		return fmt.Sprintf("offset %d", l.Offset)
+	}
+	return fmt.Sprintf("line %d column %d", l.Line, l.Column)
+}
+
+// anchor maps the output from out on to the input from in, byte by byte till the next anchor.
+type anchor struct {
+	out, in int64
+}
+
+// Tracker maps the offsets of the converted output back to the original input. It keeps the last bytes of the input so that
+// the line and column of an offset can be found. It can be used while the input is being converted.
+type Tracker struct {
+	mu      sync.Mutex
+	anchors []anchor //sorted, in - out changes at every anchor.
+	window  []byte   //last bytes of the input.
+	start   int64    //offset of window[0] in the input.
+	line    int      //line of window[0], 0 if it is not known.
+	column  int      //column of the first byte of the input.
+	base    int64    //offset in the file of the first byte of the input.
+	shift   int64    //added to the offsets of the output, see ResumeArray.
+}
+
+func newTracker() *Tracker {
+	return &Tracker{anchors: []anchor{{}}, line: 1, column: 1}
+}
+
+// Track returns a reader of r which tracks the lines of r. The offsets are not changed, it is used for input which is not converted.
+func Track(r io.Reader) (io.Reader, *Tracker) {
+	t := newTracker()
+	return &trackReader{r: r, t: t}, t
+}
+
+type trackReader struct {
+	r io.Reader
+	t *Tracker
+}
+
+func (tr *trackReader) Read(b []byte) (int, error) {
+	n, err := tr.r.Read(b)
+	tr.t.add(b[:n], nil)
+	return n, err
+}
+
+// Resume tells that the input was read from offset base of the file, like after a checkpoint, which is at line and column.
+// The locations are reported in the file, if line is 0 only the offsets are known.
+func (t *Tracker) Resume(base int64, line, column int) {
+	t.mu.Lock()
+	t.base, t.line, t.column = base, line, column
+	t.mu.Unlock()
+}
+
+// ResumeArray returns the rest of an array which is read from the end of one of its elements, like after a checkpoint. The comma before the
+// next element is skipped and the [ is added back, so that the rest is read as an array. The offsets of the returned reader are tracked by t.
+func ResumeArray(r io.Reader, t *Tracker) io.Reader {
+
+	br := bufio.NewReader(r)
+	skipped := int64(0)
+
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			break
+		}
+		if c == ',' {
+			skipped++
+			break
+		}
+		if strings.IndexByte(" \t\r\n", c) < 0 {
+			br.UnreadByte()
+			break
+		}
+		skipped++
+	}
+
+	t.mu.Lock()
+	t.shift = skipped - 1 //the [ is not in the input.
+	t.mu.Unlock()
+
+	return io.MultiReader(strings.NewReader("["), br)
+}
+
+// add appends the input to the window and adds the anchors of its output. The oldest input is dropped and the anchors
+// before it are pruned, the offsets asked by the decoder only grow.
+func (t *Tracker) add(input []byte, anchors []anchor) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.window = append(t.window, input...)
+	t.anchors = append(t.anchors, anchors...)
+
+	if len(t.window) <= 2*windowSize {
+		return
+	}
+
+	drop := len(t.window) - windowSize
+	if t.line > 0 {
+		t.line += bytes.Count(t.window[:drop], []byte{'\n'})
+	}
+	t.window = append(t.window[:0], t.window[drop:]...)
+	t.start += int64(drop)
+
+	i := sort.Search(len(t.anchors), func(i int) bool { return t.anchors[i].in > t.start })
+	if i > 1 {
+		t.anchors = append(t.anchors[:0], t.anchors[i-1:]...) //the last anchor before the window is kept, it maps the start of the window.
+	}
+}
+
+// Locate returns the location in the input of the offset of the output.
+func (t *Tracker) Locate(offset int64) Location {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	offset += t.shift
+	if offset < 0 {
+		offset = 0
+	}
+
+	i := sort.Search(len(t.anchors), func(i int) bool { return t.anchors[i].out > offset }) - 1
+	if i < 0 {
+		i = 0
+	}
+	a := t.anchors[i]
+
+	in := a.in + offset - a.out
+	loc := Location{Offset: t.base + in}
+
+	at := in - t.start
+	if at < 0 || at > int64(len(t.window)) || t.line <= 0 {
+		return loc //too old, only the offset is known.
+	}
+
+	before := t.window[:at]
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	lineEnd := len(t.window)
+	if end := bytes.IndexByte(t.window[lineStart:], '\n'); end >= 0 {
+		lineEnd = lineStart + end
+	}
+
+	loc.Line = t.line + bytes.Count(before, []byte{'\n'})
+	if lineStart == 0 && t.start > 0 {
+		return loc //the start of the line was dropped, the column is not known.
+	}
+
+	loc.Column = utf8.RuneCount(before[lineStart:]) + 1
+	loc.Snippet = snippet(string(bytes.TrimRight(t.window[lineStart:lineEnd], "\r")), loc.Column)
+
+	if lineStart == 0 && t.start == 0 { //the first line of the input starts at the column of the input.
+		loc.Column += t.column - 1
+	}
+
+	return loc
+}
+
+// snippet returns the line with a marker under the column. Long lines are cut around the column.
+func snippet(line string, column int) string {
+
+	runes := []rune(strings.ReplaceAll(line, "\t", " ")) //tabs would move the marker.
+	col := column - 1
+
+	if len(runes) > maxSnippet {
+		from := col - maxSnippet/2
+		if from < 0 {
+			from = 0
+		}
+		to := from + maxSnippet
+		if to > len(runes) {
+			to, from = len(runes), len(runes)-maxSnippet
+		}
+		runes, col = runes[from:to], col-from
+	}
+
+	return string(runes) + "\n" + strings.Repeat(" ", col) + "^"
+}
@@ -0,0 +1,415 @@
+package expr
+
+import (
+	"encoding/json"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Eval evaluates the expression on the record. Numbers are computed exactly and returned as json.Number, paths which do not exist are null.
+// Operations on values of the wrong type, like "a" * 2, return null instead of failing the record.
+func (e *Expr) Eval(record map[string]any) any {
+	return Export(e.root.eval(record))
+}
+
+// Match evaluates the expression on the record and reports if the result is true. null, false, 0 and "" are false.
+func (e *Expr) Match(record map[string]any) bool {
+	return truthy(e.root.eval(record))
+}
+
+// Export converts the numbers of the evaluation to json.Number, other values are returned as they are.
+func Export(v any) any {
+	switch n := v.(type) {
+	case *big.Rat:
+		return json.Number(ratString(n))
+	case int:
+		return json.Number(strconv.Itoa(n))
+	}
+	return v
+}
+
+type node interface {
+	eval(record map[string]any) any
+}
+
+type literal struct {
+	value any
+}
+
+func (l *literal) eval(map[string]any) any {
+	return l.value
+}
+
+// path is a path into the record. Keys are strings for objects and ints for array indexes.
+type path struct {
+	keys []any
+}
+
+func (p *path) eval(record map[string]any) any {
+	v, _ := p.lookup(record)
+	return v
+}
+
+func (p *path) lookup(record map[string]any) (any, bool) {
+
+	var v any = record
+
+	for _, key := range p.keys {
+		switch k := key.(type) {
+		case string:
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = m[k]; !ok {
+				return nil, false
+			}
+		case int:
+			a, ok := v.([]any)
+			if !ok || k >= len(a) {
+				return nil, false
+			}
+			v = a[k]
+		}
+	}
+
+	return v, true
+}
+
+// has is true if the path exists in the record and is not null.
+type has struct {
+	path *path
+}
+
+func (h *has) eval(record map[string]any) any {
+	v, ok := h.path.lookup(record)
+	return ok && v != nil
+}
+
+type unary struct {
+	op string
+	x  node
+}
+
+func (u *unary) eval(record map[string]any) any {
+
+	x := u.x.eval(record)
+
+	if u.op == "!" {
+		return !truthy(x)
+	}
+
+	if r, ok := toNumber(x); ok {
+		return new(big.Rat).Neg(r)
+	}
+
+	return nil
+}
+
+type binary struct {
+	op   string
+	x, y node
+}
+
+func (b *binary) eval(record map[string]any) any {
+
+	x := b.x.eval(record)
+
+	switch b.op { //short circuit.
+	case "&&":
+		return truthy(x) && truthy(b.y.eval(record))
+	case "||":
+		return truthy(x) || truthy(b.y.eval(record))
+	}
+
+	y := b.y.eval(record)
+
+	switch b.op {
+	case "==":
+		return equal(x, y)
+	case "!=":
+		return !equal(x, y)
+	case "<", "<=", ">", ">=":
+		c, ok := compare(x, y)
+		if !ok {
+			return false
+		}
+		switch b.op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		}
+		return c >= 0
+	case "+":
+		_, xs := x.(string)
+		_, ys := y.(string)
+		if xs || ys { //strings are concatenated, null is empty.
+			return String(x) + String(y)
+		}
+	}
+
+	return arithmetic(b.op, x, y)
+}
+
+func arithmetic(op string, x, y any) any {
+
+	a, ok := toNumber(x)
+	if !ok {
+		return nil
+	}
+	b, ok := toNumber(y)
+	if !ok {
+		return nil
+	}
+
+	switch op {
+	case "+":
+		return new(big.Rat).Add(a, b)
+	case "-":
+		return new(big.Rat).Sub(a, b)
+	case "*":
+		return new(big.Rat).Mul(a, b)
+	case "/":
+		if b.Sign() == 0 {
+			return nil
+		}
+		return new(big.Rat).Quo(a, b)
+	case "%":
+		if !a.IsInt() || !b.IsInt() || b.Sign() == 0 {
+			return nil
+		}
+		return new(big.Rat).SetInt(new(big.Int).Rem(a.Num(), b.Num()))
+	}
+
+	return nil
+}
+
+type match struct {
+	x   node
+	re  *regexp.Regexp
+	not bool
+}
+
+func (m *match) eval(record map[string]any) any {
+
+	x := m.x.eval(record)
+	if x == nil {
+		return false
+	}
+
+	return m.re.MatchString(String(x)) != m.not
+}
+
+type call struct {
+	fn   Func
+	args []node
+}
+
+func (c *call) eval(record map[string]any) any {
+
+	args := make([]any, len(c.args))
+	for i, arg := range c.args {
+		args[i] = Export(arg.eval(record))
+	}
+
+	return c.fn.Call(args)
+}
+
+// toNumber converts numbers and numeric strings to big.Rat.
+func toNumber(v any) (*big.Rat, bool) {
+
+	switch n := v.(type) {
+	case *big.Rat:
+		return n, true
+	case json.Number:
+		return new(big.Rat).SetString(n.String())
+	case float64:
+		r := new(big.Rat)
+		if r.SetFloat64(n) == nil {
+			return nil, false
+		}
+		return r, true
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case string:
+		return new(big.Rat).SetString(strings.TrimSpace(n))
+	}
+
+	return nil, false
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case *big.Rat, json.Number, float64, int, int64:
+		return true
+	}
+	return false
+}
+
+// compare compares numbers, numeric strings with numbers, and strings. Other values are not ordered.
+func compare(x, y any) (int, bool) {
+
+	if isNumber(x) || isNumber(y) {
+		a, ok := toNumber(x)
+		if !ok {
+			return 0, false
+		}
+		b, ok := toNumber(y)
+		if !ok {
+			return 0, false
+		}
+		return a.Cmp(b), true
+	}
+
+	a, ok := x.(string)
+	if !ok {
+		return 0, false
+	}
+	b, ok := y.(string)
+	if !ok {
+		return 0, false
+	}
+
+	return strings.Compare(a, b), true
+}
+
+func equal(x, y any) bool {
+
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+
+	if c, ok := compare(x, y); ok {
+		return c == 0
+	}
+
+	a, ok := x.(bool)
+	if !ok {
+		return false
+	}
+	b, ok := y.(bool)
+
+	return ok && a == b
+}
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	}
+	if r, ok := toNumber(v); ok {
+		return r.Sign() != 0
+	}
+	return true
+}
+
+// String returns the text of the value like it is written in the csv. null is empty.
+func String(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case *big.Rat:
+		return ratString(x)
+	case json.Number:
+		return x.String()
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// ratString writes the number as a decimal. Numbers which do not have a finite decimal, like 1/3, are rounded to 16 decimals.
+func ratString(r *big.Rat) string {
+
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	denom := new(big.Int).Set(r.Denom())
+	factors := [2]int{}
+	for i, f := range []int64{2, 5} {
+		for m := new(big.Int); ; factors[i]++ {
+			q, rem := new(big.Int).QuoRem(denom, big.NewInt(f), m)
+			if rem.Sign() != 0 {
+				break
+			}
+			denom = q
+		}
+	}
+
+	decimals := factors[0]
+	if factors[1] > decimals {
+		decimals = factors[1]
+	}
+
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		return strings.TrimRight(strings.TrimRight(r.FloatString(16), "0"), ".")
+	}
+
+	return r.FloatString(decimals)
+}
+
+// builtins are the functions which can be used in every expression.
+var builtins = map[string]Func{
+	"len": {Min: 1, Max: 1, Call: func(args []any) any {
+		switch v := args[0].(type) {
+		case string:
+			return utf8.RuneCountInString(v)
+		case []any:
+			return len(v)
+		case map[string]any:
+			return len(v)
+		}
+		return nil
+	}},
+	"lower":      {Min: 1, Max: 1, Call: func(args []any) any { return nullOr(args[0], strings.ToLower) }},
+	"upper":      {Min: 1, Max: 1, Call: func(args []any) any { return nullOr(args[0], strings.ToUpper) }},
+	"trim":       {Min: 1, Max: 1, Call: func(args []any) any { return nullOr(args[0], strings.TrimSpace) }},
+	"contains":   {Min: 2, Max: 2, Call: func(args []any) any { return strings.Contains(String(args[0]), String(args[1])) }},
+	"startsWith": {Min: 2, Max: 2, Call: func(args []any) any { return strings.HasPrefix(String(args[0]), String(args[1])) }},
+	"endsWith":   {Min: 2, Max: 2, Call: func(args []any) any { return strings.HasSuffix(String(args[0]), String(args[1])) }},
+	"string":     {Min: 1, Max: 1, Call: func(args []any) any { return String(args[0]) }},
+	"number": {Min: 1, Max: 1, Call: func(args []any) any {
+		if r, ok := toNumber(args[0]); ok {
+			return r
+		}
+		return nil
+	}},
+	"coalesce": {Min: 1, Max: -1, Call: func(args []any) any {
+		for _, arg := range args {
+			if arg != nil {
+				return arg
+			}
+		}
+		return nil
+	}},
+}
+
+// nullOr applies fn to the text of the value, null stays null.
+func nullOr(v any, fn func(string) string) any {
+	if v == nil {
+		return nil
+	}
+	return fn(String(v))
+}
@@ -0,0 +1,124 @@
+package expr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+
+	record := map[string]any{}
+	decoder := json.NewDecoder(strings.NewReader(`{"status":"active","age":18,"price":0.1,"qty":3,"fname":"Ada","lname":"L",
+		"address":{"zip":null,"city":"Pune"},"items":[{"sku":"a-1"}],"first name":"x","id":"42"}`))
+	decoder.UseNumber()
+	if err := decoder.Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		src  string
+		want any
+	}{
+		{`status == "active" && age >= 18`, true},
+		{`status == 'active' && age > 18`, false},
+		{`has(address.city) && !has(address.zip) && !has(missing.key)`, true},
+		{`address.zip == null && missing != null`, false},
+		{`address.zip == null && missing == null`, true},
+		{`items[0].sku =~ "^[a-z]-\d$"`, true},
+		{`items[1].sku !~ "x"`, false},
+		{`["first name"] == "x" || false`, true},
+		{`price * qty`, json.Number("0.3")},
+		{`(age + 2) / 4 % 3`, json.Number("2")},
+		{`1 / 3`, json.Number("0.3333333333333333")},
+		{`-age + 1e1`, json.Number("-8")},
+		{`fname + " " + lname`, "Ada L"},
+		{`id > 9 && id == 42`, true},
+		{`status * 2`, nil},
+		{`upper(address.city) + len(items)`, "PUNE1"},
+		{`coalesce(address.zip, "none")`, "none"},
+	}
+
+	for _, tt := range tests {
+		e, err := Compile(tt.src, nil)
+		if err != nil {
+			t.Errorf("%s : %v", tt.src, err)
+			continue
+		}
+		if got := e.Eval(record); got != tt.want {
+			t.Errorf("%s : expected %#v, got %#v", tt.src, tt.want, got)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+
+	tests := []struct {
+		src  string
+		pos  int
+		want string
+	}{
+		{`status = "active"`, 7, `unexpected "=", use "==" to compare`},
+		{`age >= `, 7, "unexpected end of expression"},
+		{`(a && b`, 7, `expected ")", got end of expression`},
+		{`name =~ "("`, 8, "invalid regexp"},
+		{`"open`, 0, "unterminated string"},
+		{`nope(a)`, 0, "unknown function nope"},
+		{`has(1)`, 0, "has needs one path"},
+		{`lower(a, b)`, 0, "lower takes 1 argument, got 2"},
+		{`a # b`, 2, "unexpected character #"},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.src, nil)
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s : expected an error, got %v", tt.src, err)
+			continue
+		}
+		if e.Pos != tt.pos || !strings.HasPrefix(e.Msg, tt.want) {
+			t.Errorf("%s : expected %q at %d, got %q at %d", tt.src, tt.want, tt.pos, e.Msg, e.Pos)
+		}
+	}
+
+	_, err := Compile(`age >= `, nil)
+	if want := "unexpected end of expression at position 8\n\tage >= \n\t       ^"; err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestParsePipeline(t *testing.T) {
+
+	calls, err := ParsePipeline(`trim | replace("\s+", ' ') |pad(-10, "0")|bool(true)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Call{
+		{Name: "trim", Pos: 0},
+		{Name: "replace", Args: []any{`\s+`, " "}, Pos: 7},
+		{Name: "pad", Args: []any{json.Number("-10"), "0"}, Pos: 28},
+		{Name: "bool", Args: []any{true}, Pos: 42},
+	}
+
+	if len(calls) != len(want) {
+		t.Fatalf("expected %d steps, got %d", len(want), len(calls))
+	}
+	for i := range want {
+		if calls[i].Name != want[i].Name || calls[i].Pos != want[i].Pos || len(calls[i].Args) != len(want[i].Args) {
+			t.Errorf("step %d : expected %+v, got %+v", i, want[i], calls[i])
+			continue
+		}
+		for j := range want[i].Args {
+			if calls[i].Args[j] != want[i].Args[j] {
+				t.Errorf("step %d : expected %+v, got %+v", i, want[i], calls[i])
+			}
+		}
+	}
+
+	for _, src := range []string{"", "trim upper", "pad(a)", "pad(1,", "|trim"} {
+		if _, err := ParsePipeline(src); err == nil {
+			t.Errorf("%q : expected an error", src)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package expr
+
+import (
+	"strings"
+)
+
+// Kinds of the tokens.
+const (
+	tokEOF = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp //operators and punctuation.
+)
+
+// operators are sorted so that the longer operators are matched first.
+var operators = []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", ",", ".", "[", "]", "|", "="}
+
+type token struct {
+	kind int
+	text string //unquoted text for strings.
+	pos  int    //byte offset in the expression.
+}
+
+// lex splits the expression into tokens.
+func lex(src string) ([]token, error) {
+
+	tokens := []token{}
+
+	for i := 0; i < len(src); {
+
+		c := src[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isDigit(c):
+			start := i
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i+1 < len(src) && src[i] == '.' && isDigit(src[i+1]) {
+				i++
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && isDigit(src[j]) {
+					for i = j; i < len(src) && isDigit(src[i]); i++ {
+					}
+				}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], pos: start})
+		case c == '"' || c == '\'':
+			text, end, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, pos: i})
+			i = end
+		case isLetter(c):
+			start := i
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:i], pos: start})
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, &Error{Expr: src, Pos: i, Msg: "unexpected character " + string(src[i])}
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString reads the quoted string starting at i and returns its text and the offset after the closing quote.
+func lexString(src string, i int) (string, int, error) {
+
+	quote := src[i]
+
+	var b strings.Builder
+	for j := i + 1; j < len(src); j++ {
+
+		switch src[j] {
+		case quote:
+			return b.String(), j + 1, nil
+		case '\\':
+			j++
+			if j >= len(src) {
+				break
+			}
+			switch src[j] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default: //quotes, backslashes and regexp escapes like \d are kept as they are.
+				if src[j] != quote && src[j] != '\\' {
+					b.WriteByte('\\')
+				}
+				b.WriteByte(src[j])
+			}
+		default:
+			b.WriteByte(src[j])
+		}
+	}
+
+	return "", 0, &Error{Expr: src, Pos: i, Msg: "unterminated string"}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || c >= 0x80
+}
@@ -0,0 +1,395 @@
+package expr
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error is an error in the expression. Pos is the byte offset where the error is.
+type Error struct {
+	Expr string
+	Pos  int
+	Msg  string
+}
+
+// Error returns the message with the expression and a marker under the position of the error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d\n\t%s\n\t%s^", e.Msg, e.Pos+1, e.Expr, strings.Repeat(" ", utf8.RuneCountInString(e.Expr[:e.Pos])))
+}
+
+// Func is a function which can be called from the expressions. Min and Max are the number of arguments, Max -1 means any number.
+type Func struct {
+	Min, Max int
+	Call     func(args []any) any
+}
+
+// Expr is a compiled expression.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile compiles the expression. funcs are added to the builtin functions.
+//
+// The expressions have paths into the record like address.zip, items[0].sku or ["first name"], numbers, strings in single or double quotes,
+// true, false and null, the operators + - * / % == != < <= > >= =~ !~ (regexp match) ! && || and function calls like has(address.zip).
+func Compile(src string, funcs map[string]Func) (*Expr, error) {
+
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{src: src, tokens: tokens, funcs: funcs}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", describe(t))
+	}
+
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	i      int
+	funcs  map[string]Func
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the operators.
+func (p *parser) accept(ops ...string) (token, bool) {
+	t := p.peek()
+	if t.kind != tokOp {
+		return t, false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return p.next(), true
+		}
+	}
+	return t, false
+}
+
+func (p *parser) expect(op string) error {
+	if t, ok := p.accept(op); !ok {
+		return p.errorf(t, "expected %q, got %s", op, describe(t))
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &Error{Expr: p.src, Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func (p *parser) parseOr() (node, error) {
+
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return x, nil
+		}
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op: "||", x: x, y: y}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+
+	x, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return x, nil
+		}
+		y, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op: "&&", x: x, y: y}
+	}
+}
+
+// parseCompare parses a comparison. Comparisons can not be chained, a < b < c is an error.
+func (p *parser) parseCompare() (node, error) {
+
+	x, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "=~", "!~")
+	if !ok {
+		if t.kind == tokOp && t.text == "=" {
+			return nil, p.errorf(t, "unexpected \"=\", use \"==\" to compare")
+		}
+		return x, nil
+	}
+
+	if t.text == "=~" || t.text == "!~" {
+		pattern := p.next()
+		if pattern.kind != tokString {
+			return nil, p.errorf(pattern, "expected a regexp string after %s, got %s", t.text, describe(pattern))
+		}
+		re, err := regexp.Compile(pattern.text)
+		if err != nil {
+			return nil, p.errorf(pattern, "invalid regexp : %v", err)
+		}
+		return &match{x: x, re: re, not: t.text == "!~"}, nil
+	}
+
+	y, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+
+	return &binary{op: t.text, x: x, y: y}, nil
+}
+
+func (p *parser) parseAdd() (node, error) {
+
+	x, err := p.parseMul()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := p.accept("+", "-")
+		if !ok {
+			return x, nil
+		}
+		y, err := p.parseMul()
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op: t.text, x: x, y: y}
+	}
+}
+
+func (p *parser) parseMul() (node, error) {
+
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := p.accept("*", "/", "%")
+		if !ok {
+			return x, nil
+		}
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op: t.text, x: x, y: y}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+
+	if t, ok := p.accept("!", "-"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: t.text, x: x}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+
+	t := p.peek()
+
+	switch t.kind {
+	case tokNumber:
+		p.next()
+		r, ok := new(big.Rat).SetString(t.text)
+		if !ok {
+			return nil, p.errorf(t, "invalid number %s", t.text)
+		}
+		return &literal{value: r}, nil
+	case tokString:
+		p.next()
+		return &literal{value: t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			p.next()
+			return &literal{value: t.text == "true"}, nil
+		case "null":
+			p.next()
+			return &literal{}, nil
+		}
+		if next := p.tokens[p.i+1]; next.kind == tokOp && next.text == "(" {
+			return p.parseCall()
+		}
+		return p.parsePath()
+	case tokOp:
+		switch t.text {
+		case "(":
+			p.next()
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			return p.parsePath()
+		}
+	}
+
+	return nil, p.errorf(t, "unexpected %s", describe(t))
+}
+
+// parsePath parses a path like address.zip, items[0].sku or ["first name"].
+func (p *parser) parsePath() (node, error) {
+
+	target := &path{}
+
+	if t := p.peek(); t.kind == tokIdent {
+		target.keys = append(target.keys, p.next().text)
+	}
+
+	for {
+		if _, ok := p.accept("."); ok {
+			t := p.next()
+			if t.kind != tokIdent {
+				return nil, p.errorf(t, "expected a key after \".\", got %s", describe(t))
+			}
+			target.keys = append(target.keys, t.text)
+			continue
+		}
+
+		if _, ok := p.accept("["); ok {
+			t := p.next()
+			switch t.kind {
+			case tokString:
+				target.keys = append(target.keys, t.text)
+			case tokNumber:
+				index, err := strconv.Atoi(t.text)
+				if err != nil || index < 0 {
+					return nil, p.errorf(t, "invalid index %s", t.text)
+				}
+				target.keys = append(target.keys, index)
+			default:
+				return nil, p.errorf(t, "expected a key or an index, got %s", describe(t))
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return target, nil
+	}
+}
+
+// parseCall parses a function call. has is not a function, its argument is a path which is checked for existence.
+func (p *parser) parseCall() (node, error) {
+
+	name := p.next()
+	p.next() //(
+
+	args := []node{}
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); ok {
+				continue
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	if name.text == "has" {
+		var target *path
+		if len(args) == 1 {
+			target, _ = args[0].(*path)
+		}
+		if target == nil {
+			return nil, p.errorf(name, "has needs one path, usage has(address.zip)")
+		}
+		return &has{path: target}, nil
+	}
+
+	fn, ok := p.funcs[name.text]
+	if !ok {
+		fn, ok = builtins[name.text]
+	}
+	if !ok {
+		return nil, p.errorf(name, "unknown function %s", name.text)
+	}
+
+	if len(args) < fn.Min || (fn.Max >= 0 && len(args) > fn.Max) {
+		return nil, p.errorf(name, "%s takes %s, got %d", name.text, arity(fn), len(args))
+	}
+
+	return &call{fn: fn, args: args}, nil
+}
+
+func arity(fn Func) string {
+	switch {
+	case fn.Min == fn.Max && fn.Min == 1:
+		return "1 argument"
+	case fn.Min == fn.Max:
+		return fmt.Sprintf("%d arguments", fn.Min)
+	case fn.Max < 0:
+		return fmt.Sprintf("at least %d arguments", fn.Min)
+	}
+	return fmt.Sprintf("%d to %d arguments", fn.Min, fn.Max)
+}
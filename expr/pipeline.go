@@ -0,0 +1,87 @@
+package expr
+
+import (
+	"encoding/json"
+)
+
+// Call is a step of a pipeline, a name with literal arguments like replace("-", ""). Pos is the byte offset of the name.
+type Call struct {
+	Name string
+	Args []any //strings, json.Number, bool or nil.
+	Pos  int
+}
+
+// ParsePipeline parses the steps of a pipeline separated by |, like trim|upper|pad(10, "0"). The arguments are literals.
+func ParsePipeline(src string) ([]Call, error) {
+
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{src: src, tokens: tokens}
+	calls := []Call{}
+
+	for {
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, p.errorf(name, "expected a step, got %s", describe(name))
+		}
+
+		call := Call{Name: name.text, Pos: name.pos}
+
+		if _, ok := p.accept("("); ok {
+			if _, ok := p.accept(")"); !ok {
+				for {
+					arg, err := p.parseLiteral()
+					if err != nil {
+						return nil, err
+					}
+					call.Args = append(call.Args, arg)
+					if _, ok := p.accept(","); ok {
+						continue
+					}
+					if err := p.expect(")"); err != nil {
+						return nil, err
+					}
+					break
+				}
+			}
+		}
+
+		calls = append(calls, call)
+
+		if _, ok := p.accept("|"); !ok {
+			break
+		}
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "expected \"|\", got %s", describe(t))
+	}
+
+	return calls, nil
+}
+
+func (p *parser) parseLiteral() (any, error) {
+
+	neg := ""
+	if _, ok := p.accept("-"); ok {
+		neg = "-"
+	}
+
+	t := p.next()
+	switch {
+	case t.kind == tokNumber:
+		return json.Number(neg + t.text), nil
+	case neg != "":
+	case t.kind == tokString:
+		return t.text, nil
+	case t.kind == tokIdent && (t.text == "true" || t.text == "false"):
+		return t.text == "true", nil
+	case t.kind == tokIdent && t.text == "null":
+		return nil, nil
+	}
+
+	return nil, p.errorf(t, "expected a number, a string, true, false or null, got %s", describe(t))
+}
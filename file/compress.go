@@ -0,0 +1,141 @@
+package file
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Compressions of the output files.
+const (
+	CompressNone = "none"
+	CompressZip  = "zip"
+	CompressGzip = "gzip"
+	CompressZstd = "zstd" //known by its extension, but there is no zstd encoder in the standard library.
+)
+
+// compressionExts are the extensions of the compressed output files.
+var compressionExts = map[string]string{CompressZip: ".zip", CompressGzip: ".gz", CompressZstd: ".zst"}
+
+// Compression returns the compression of the path from its extension, none if it is not compressed.
+func Compression(path string) string {
+
+	ext := strings.ToLower(filepath.Ext(path))
+	for compression, e := range compressionExts {
+		if ext == e {
+			return compression
+		}
+	}
+
+	return CompressNone
+}
+
+// SetCompression sets the compression of the output files and its level, -1 is the default level. Empty compression uses the extension of
+// the output path. The csv files are compressed while they are written, a zip file has a single csv file named like the zip file.
+func (o *Outputs) SetCompression(compression string, level int) error {
+
+	if compression == "" {
+		compression = Compression(o.path)
+	}
+
+	switch compression {
+	case CompressNone, CompressZip, CompressGzip:
+	case CompressZstd:
+		return errors.New("zstd compression is not supported, use zip or gzip")
+	default:
+		return fmt.Errorf("unknown compression %q, allowed values are %s, %s and %s", compression, CompressNone, CompressZip, CompressGzip)
+	}
+
+	if compression == CompressZip && o.Policy == ExistsAppend {
+		return errors.New("rows can not be appended to a zip file, use gzip or no compression with --append")
+	}
+
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return fmt.Errorf("compression level should be between %d and %d, got : %d", flate.HuffmanOnly, flate.BestCompression, level)
+	}
+
+	if ext := compressionExts[compression]; ext != "" && strings.EqualFold(filepath.Ext(o.path), ext) { //orders.csv.gz is the path of orders.csv.
+		o.path = o.path[:len(o.path)-len(ext)]
+		if filepath.Ext(o.path) == "" {
+			o.path += ".csv"
+		}
+	}
+
+	o.compression, o.level = compression, level
+	return nil
+}
+
+// Compressed returns true if the output files are compressed.
+func (o *Outputs) Compressed() bool {
+	return o.compression != CompressNone
+}
+
+// compressedPath returns the path of the compressed csv file. The csv orders.csv is written to orders.zip or orders.csv.gz.
+func (o *Outputs) compressedPath(path string) string {
+
+	switch o.compression {
+	case CompressZip:
+		return path[:len(path)-len(filepath.Ext(path))] + compressionExts[CompressZip]
+	case CompressGzip:
+		return path + compressionExts[CompressGzip]
+	}
+
+	return path
+}
+
+// compress returns the writer which compresses the csv file name into w. The closer should be closed before w.
+func (o *Outputs) compress(w io.Writer, name string) (io.Writer, io.Closer, error) {
+
+	switch o.compression {
+	case CompressGzip:
+		zw, err := gzip.NewWriterLevel(w, o.level)
+		if err != nil {
+			return nil, nil, err
+		}
+		zw.Name = name
+		return zw, zw, nil
+	case CompressZip:
+		zw := zip.NewWriter(w)
+		zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, o.level)
+		})
+		entry, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return nil, nil, err
+		}
+		return entry, zw, nil //closing the zip writer closes the entry and writes the directory.
+	}
+
+	return w, nil, nil
+}
+
+// decompress returns the reader of the csv file in the compressed file fh.
+func (o *Outputs) decompress(fh *os.File) (io.Reader, error) {
+
+	switch o.compression {
+	case CompressGzip:
+		return gzip.NewReader(fh) //the rows appended later are new gzip members, they are read as one stream.
+	case CompressZip:
+		info, err := fh.Stat()
+		if err != nil {
+			return nil, err
+		}
+		zr, err := zip.NewReader(fh, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		if len(zr.File) != 1 {
+			return nil, fmt.Errorf("%s should have a single csv file, got %d files", fh.Name(), len(zr.File))
+		}
+		return zr.File[0].Open()
+	}
+
+	return fh, nil
+}
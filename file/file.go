@@ -3,12 +3,15 @@ package file
 import (
 	"archive/zip"
 	"bufio"
+	"compress/flate"
 	"encoding/csv"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -16,11 +19,15 @@ import (
 
 type Close func()
 
-func GetInputReader(inFile string, isStdin bool, logger *zerolog.Logger) (io.Reader, Close) {
+// GetInputReader opens the input and skips to offset, the input is read from there. Files are seeked, zip files are read till the offset.
+func GetInputReader(inFile string, isStdin bool, offset int64, logger *zerolog.Logger) (io.Reader, Close) {
 
 	c := (func() {})
 
 	if isStdin {
+		if offset > 0 {
+			logger.Fatal().Msg("can not resume reading from stdin")
+		}
 		return os.Stdin, c
 	}
 
@@ -42,12 +49,62 @@ func GetInputReader(inFile string, isStdin bool, logger *zerolog.Logger) (io.Rea
 	}
 	c = func() { closeFile(fh, logger) }
 
+	if offset > 0 {
+		if err := skip(fh, offset); err != nil {
+			logger.Fatal().Err(err).Msgf("error while skipping to offset %d of the input", offset)
+		}
+		logger.Info().Msgf("Reading input from path : %s, offset : %d", inFile, offset)
+		return bufio.NewReader(fh), c
+	}
+
 	logger.Info().Msgf("Reading input from path : %s", inFile)
 
 	return bufio.NewReader(fh), c
 }
 
-func GetOutWriter(inFile, outFile string, isZip bool, logger *zerolog.Logger) (*csv.Writer, string, Close) {
+// skip moves r to the offset, by seeking if it can.
+func skip(r io.Reader, offset int64) error {
+
+	if s, ok := r.(io.Seeker); ok {
+		_, err := s.Seek(offset, io.SeekStart)
+		return err
+	}
+
+	_, err := io.CopyN(io.Discard, r, offset)
+	return err
+}
+
+// What happens when an output file exists.
+const (
+	ExistsError     = "error"      //the conversion stops with an error.
+	ExistsOverwrite = "overwrite"  //the file is replaced when the conversion succeeds.
+	ExistsNoClobber = "no-clobber" //the file is kept and nothing is converted.
+	ExistsAppend    = "append"     //the rows are appended to the file when the conversion succeeds, the header row is not written again.
+)
+
+// partSuffix is added to the path of the output files while they are written, they are renamed to the path when the conversion succeeds.
+// In append mode the part files have only the new rows, they are appended to the path instead.
+const partSuffix = ".part"
+
+// Outputs creates the csv writers of the output tables. The root table is written to the output path and the
+// tables of normalize mode are written next to it, suffixed with the table name.
+type Outputs struct {
+	Comma       rune   //Delimeter used by every writer.
+	Policy      string //What happens when an output file exists, ExistsError by default.
+	path        string
+	files       []*os.File
+	paths       []string
+	parts       []string         //part files being written.
+	closers     []io.Closer      //compressors of the files, nil if a file is not compressed.
+	committed   bool             //true once the part files are moved to their paths.
+	failed      bool             //true if a file could not be closed, it is not committed.
+	resume      map[string]int64 //sizes of the files written before a checkpoint, they are continued instead of created.
+	compression string
+	level       int
+	logger      *zerolog.Logger
+}
+
+func GetOutWriter(inFile, outFile string, logger *zerolog.Logger) *Outputs {
 
 	if inFile == "" { //In case of reading from stdin, we will get empty file name
 		inFile = "stdin"
@@ -61,82 +118,347 @@ func GetOutWriter(inFile, outFile string, isZip bool, logger *zerolog.Logger) (*
 		outFile = fmt.Sprintf("j2csv-%s-%d.%s", fname, ts, "csv")
 	}
 
-	fh, err := os.OpenFile(outFile, os.O_CREATE|os.O_RDWR, 0644)
+	return &Outputs{
+		Comma:       ',',
+		Policy:      ExistsError,
+		path:        outFile,
+		compression: CompressNone,
+		level:       flate.DefaultCompression,
+		logger:      logger,
+	}
+}
+
+// Writer creates the output file of the table and returns a csv writer for it. Empty name is the root table.
+func (o *Outputs) Writer(table string) (*csv.Writer, error) {
+
+	path := o.TablePath(table)
+
+	part := path + partSuffix
+
+	var fh *os.File
+	var err error
+
+	if size, ok := o.resume[path]; ok {
+		fh, err = openAt(part, size) //rows written after the checkpoint are dropped.
+	} else if err = o.checkExists(path); err == nil {
+		fh, err = os.OpenFile(part, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644) //a part file left by an earlier run is replaced.
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out, closer, err := o.compress(fh, filepath.Base(o.csvPath(table)))
 	if err != nil {
-		logger.Fatal().Err(err).Msg("error while creating output file")
+		fh.Close()
+		os.Remove(part)
+		return nil, fmt.Errorf("error while creating compressed output file %s : %w", path, err)
 	}
 
-	c := Close(func() { closeFile(fh, logger) })
+	o.files = append(o.files, fh)
+	o.paths = append(o.paths, path)
+	o.parts = append(o.parts, part)
+	o.closers = append(o.closers, closer)
 
-	return csv.NewWriter(fh), outFile, c
+	w := csv.NewWriter(out)
+	w.Comma = o.Comma
+	return w, nil
 }
 
-func getZipReader(inFile string, logger *zerolog.Logger) io.ReadCloser {
-	zr, err := zip.OpenReader(inFile)
+// TablePath returns the output path of the table. For table items of output orders.csv the path is orders_items.csv, orders_items.zip
+// or orders_items.csv.gz when compressed.
+func (o *Outputs) TablePath(table string) string {
+	return o.compressedPath(o.csvPath(table))
+}
+
+// csvPath returns the path of the csv file of the table before compression.
+func (o *Outputs) csvPath(table string) string {
+
+	if table == "" {
+		return o.path
+	}
+
+	ext := filepath.Ext(o.path)
+	return o.path[0:len(o.path)-len(ext)] + "_" + strings.ReplaceAll(table, ".", "_") + ext
+}
+
+// Exists returns true if the output file of the root table exists.
+func (o *Outputs) Exists() bool {
+	_, err := os.Stat(o.TablePath(""))
+	return err == nil
+}
+
+// checkExists returns an error if the output file exists and the policy does not allow to replace it or to append to it.
+func (o *Outputs) checkExists(path string) error {
+
+	if _, err := os.Stat(path); err != nil || o.Policy == ExistsOverwrite || o.Policy == ExistsAppend {
+		return nil
+	}
+
+	return fmt.Errorf("output file %s exists, use --overwrite to replace it, --append to add the rows to it or --no-clobber to keep it", path)
+}
+
+// Header returns the header row of the output file of the table in append mode, nil if the file is empty or if it is not appended to.
+func (o *Outputs) Header(table string) ([]string, error) {
+
+	if o.Policy != ExistsAppend {
+		return nil, nil
+	}
+
+	fh, err := os.Open(o.TablePath(table))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		logger.Fatal().Err(err).Msg("error while opening input zip file")
+		return nil, err
 	}
+	defer fh.Close()
 
-	if len(zr.File) > 1 {
-		logger.Fatal().Msg("Only 1 file allowed in zip")
+	in, err := o.decompress(fh)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while reading %s : %w", fh.Name(), err)
 	}
 
-	fh, err := zr.File[0].Open()
+	r := csv.NewReader(in)
+	r.Comma = o.Comma
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
 	if err != nil {
-		logger.Fatal().Msgf("error while opening file insize zip : %v", err)
+		return nil, fmt.Errorf("error while reading the header row of %s : %w", fh.Name(), err)
 	}
 
-	return fh
+	return header, nil
 }
 
-func closeFile(fh io.Closer, logger *zerolog.Logger) {
-	logger.Debug().Msg("closing file")
-	if err := fh.Close(); err != nil {
-		logger.Error().Err(err).Msg("error while closing file")
+// Commit renames the part files to the output paths, in append mode they are appended to the paths. It should be called once the files
+// are closed and the conversion succeeded.
+func (o *Outputs) Commit() error {
+
+	if o.failed {
+		return errors.New("output files are not complete, they could not be closed")
+	}
+
+	for i, part := range o.parts {
+		if o.Policy == ExistsAppend {
+			if err := appendFile(o.paths[i], part); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Rename(part, o.paths[i]); err != nil {
+			return err
+		}
 	}
+
+	o.committed = true
+	return nil
 }
 
-func isZip(name string) bool {
-	ext := filepath.Ext(name)
-	return ext == ".zip"
+// Pending returns the part files which are not moved to their paths yet, they are removed if the conversion fails.
+func (o *Outputs) Pending() []string {
+
+	if o.committed {
+		return nil
+	}
+
+	return append([]string(nil), o.parts...)
 }
 
-func ZipFile(fpath string, logger *zerolog.Logger) (string, error) {
+// appendFile appends the part file to path and removes it.
+func appendFile(path, part string) error {
 
-	fname := filepath.Base(fpath)
-	ext := filepath.Ext(fname)
-	zipName := fpath[0:len(fpath)-len(ext)] + ".zip"
+	in, err := os.Open(part)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
 
-	fh, err := os.Open(fpath)
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
-		logger.Warn().Err(err).Msg("zip : error while opening the out file")
-		return zipName, err
+		return err
 	}
-	defer fh.Close()
 
-	zh, err := os.OpenFile(zipName, os.O_CREATE|os.O_RDWR, 0644)
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(part)
+}
+
+// Resume continues the output files which were written before a checkpoint. sizes are the sizes of the files at the checkpoint by path.
+func (o *Outputs) Resume(sizes map[string]int64) {
+	o.resume = sizes
+}
+
+// Sizes syncs the output files and returns their sizes by path, the writers should be flushed first.
+func (o *Outputs) Sizes() (map[string]int64, error) {
+
+	sizes := make(map[string]int64, len(o.files))
+	for i, fh := range o.files {
+		if err := fh.Sync(); err != nil {
+			return nil, err
+		}
+		size, err := fh.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		sizes[o.paths[i]] = size
+	}
+
+	return sizes, nil
+}
+
+// openAt opens the file for writing at size, the rest of the file is removed.
+func openAt(path string, size int64) (*os.File, error) {
+
+	fh, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fh.Truncate(size); err != nil {
+		fh.Close()
+		return nil, err
+	}
+
+	if _, err := fh.Seek(size, io.SeekStart); err != nil {
+		fh.Close()
+		return nil, err
+	}
+
+	return fh, nil
+}
+
+// Paths returns the paths of the output files created till now.
+func (o *Outputs) Paths() []string {
+	return append([]string(nil), o.paths...)
+}
+
+// Close closes every output file, the compressors are closed first so that the compressed files are complete.
+func (o *Outputs) Close() {
+	for i, fh := range o.files {
+		if o.closers[i] != nil {
+			if err := o.closers[i].Close(); err != nil {
+				o.logger.Error().Err(err).Msg("error while closing compressed output file")
+				o.failed = true
+			}
+		}
+		if err := fh.Close(); err != nil {
+			o.logger.Error().Err(err).Msg("error while closing output file")
+			o.failed = true
+		}
+	}
+	o.files, o.closers = nil, nil
+}
+
+// DeadLetterPath returns the path of the file of the rejected records. For output orders.csv the path is orders_rejected.ndjson.
+func (o *Outputs) DeadLetterPath() string {
+	ext := filepath.Ext(o.path)
+	return o.path[0:len(o.path)-len(ext)] + "_rejected.ndjson"
+}
+
+// LazyFile is created on the first write, so that no empty file is left when nothing is written.
+type LazyFile struct {
+	Path   string
+	fh     *os.File
+	size   int64 //bytes written.
+	logger *zerolog.Logger
+}
+
+func NewLazyFile(path string, logger *zerolog.Logger) *LazyFile {
+	return &LazyFile{Path: path, logger: logger}
+}
+
+func (l *LazyFile) Write(b []byte) (int, error) {
+
+	if l.fh == nil {
+		fh, err := os.OpenFile(l.Path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return 0, err
+		}
+		l.fh = fh
+	}
+
+	n, err := l.fh.Write(b)
+	l.size += int64(n)
+	return n, err
+}
+
+// Resume continues the file which had size bytes at a checkpoint.
+func (l *LazyFile) Resume(size int64) error {
+
+	if size <= 0 {
+		return nil
+	}
+
+	fh, err := openAt(l.Path, size)
 	if err != nil {
-		logger.Warn().Err(err).Msg("zip : error while creating zip file")
-		return zipName, err
+		return err
+	}
+
+	l.fh, l.size = fh, size
+	return nil
+}
+
+// Size syncs the file and returns the bytes written, 0 if it was not created.
+func (l *LazyFile) Size() (int64, error) {
+
+	if l.fh == nil {
+		return 0, nil
 	}
-	defer zh.Close()
 
-	wr := zip.NewWriter(zh)
-	defer wr.Close()
+	if err := l.fh.Sync(); err != nil {
+		return 0, err
+	}
+
+	return l.size, nil
+}
+
+// Close closes the file if it was created.
+func (l *LazyFile) Close() {
+	if l.fh != nil {
+		closeFile(l.fh, l.logger)
+		l.fh = nil
+	}
+}
 
-	fwr, err := wr.Create(fname)
+func getZipReader(inFile string, logger *zerolog.Logger) io.ReadCloser {
+	zr, err := zip.OpenReader(inFile)
 	if err != nil {
-		logger.Warn().Err(err).Msg("zip : error while creating file inside zip")
-		return zipName, err
+		logger.Fatal().Err(err).Msg("error while opening input zip file")
+	}
+
+	if len(zr.File) > 1 {
+		logger.Fatal().Msg("Only 1 file allowed in zip")
 	}
 
-	n, err := io.Copy(fwr, fh)
+	fh, err := zr.File[0].Open()
 	if err != nil {
-		logger.Warn().Err(err).Msg("zip : could not copy to zip")
-		return zipName, err
+		logger.Fatal().Msgf("error while opening file insize zip : %v", err)
 	}
 
-	logger.Debug().Msgf("Copied : %d bytes to zip", n)
+	return fh
+}
 
-	return zipName, nil
+func closeFile(fh io.Closer, logger *zerolog.Logger) {
+	logger.Debug().Msg("closing file")
+	if err := fh.Close(); err != nil {
+		logger.Error().Err(err).Msg("error while closing file")
+	}
+}
+
+func isZip(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".zip"
 }
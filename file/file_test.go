@@ -0,0 +1,248 @@
+package file
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// write writes the rows to the table and flushes them.
+func write(t *testing.T, o *Outputs, table string, rows ...[]string) {
+	t.Helper()
+
+	w, err := o.Writer(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// read returns the csv file of the table decompressed.
+func read(t *testing.T, o *Outputs, table string) string {
+	t.Helper()
+
+	fh, err := os.Open(o.TablePath(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+
+	in, err := o.decompress(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+// parts returns the part files left in dir.
+func parts(t *testing.T, dir string) []string {
+	t.Helper()
+
+	found, err := filepath.Glob(filepath.Join(dir, "*"+partSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return found
+}
+
+func TestPolicies(t *testing.T) {
+
+	tests := []struct {
+		policy string
+		fails  bool
+		want   string
+	}{
+		{ExistsError, true, "a\n1\n"},
+		{ExistsNoClobber, true, "a\n1\n"}, //the conversion is skipped before, the file is never replaced.
+		{ExistsOverwrite, false, "a\n2\n"},
+		{ExistsAppend, false, "a\n1\n2\n"},
+	}
+
+	for _, tt := range tests {
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "orders.csv")
+		if err := os.WriteFile(path, []byte("a\n1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		o := GetOutWriter("orders.json", path, &zerolog.Logger{})
+		o.Policy = tt.policy
+
+		w, err := o.Writer("")
+		if (err != nil) != tt.fails {
+			t.Fatalf("%s : unexpected error %v", tt.policy, err)
+		}
+
+		if err == nil {
+			header, err := o.Header("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows := [][]string{{"2"}}
+			if header == nil {
+				rows = append([][]string{{"a"}}, rows...)
+			}
+			if err := w.WriteAll(rows); err != nil {
+				t.Fatal(err)
+			}
+			o.Close()
+			if err := o.Commit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := read(t, o, ""); got != tt.want {
+			t.Errorf("%s : expected %q, got %q", tt.policy, tt.want, got)
+		}
+		if left := parts(t, dir); len(left) > 0 {
+			t.Errorf("%s : part files left %v", tt.policy, left)
+		}
+	}
+}
+
+func TestFailedConversion(t *testing.T) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "orders.csv")
+	if err := os.WriteFile(filepath.Join(dir, "orders_items.csv"), []byte("sku\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := GetOutWriter("orders.json", path, &zerolog.Logger{})
+	write(t, o, "", []string{"id"}, []string{"1"})
+
+	if _, err := o.Writer("items"); err == nil {
+		t.Fatal("no error for the existing file of table items")
+	}
+
+	o.Close() //what the fatal hook does.
+	for _, part := range o.Pending() {
+		os.Remove(part)
+	}
+
+	if left := parts(t, dir); len(left) > 0 {
+		t.Errorf("part files left %v", left)
+	}
+	if o.Exists() {
+		t.Errorf("output file %s is created", path)
+	}
+}
+
+func TestAppendHeader(t *testing.T) {
+
+	for _, compression := range []string{CompressNone, CompressGzip} {
+
+		path := filepath.Join(t.TempDir(), "orders.csv")
+		var o *Outputs
+
+		for i, row := range []string{"1", "2"} { //every run appends a row.
+
+			o = GetOutWriter("orders.json", path, &zerolog.Logger{})
+			o.Policy = ExistsAppend
+			if err := o.SetCompression(compression, -1); err != nil {
+				t.Fatal(err)
+			}
+
+			header, err := o.Header("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (header == nil) != (i == 0) {
+				t.Fatalf("%s : unexpected header %v of run %d", compression, header, i)
+			}
+
+			rows := [][]string{{row}}
+			if header == nil {
+				rows = append([][]string{{"a"}}, rows...)
+			}
+			write(t, o, "", rows...)
+			o.Close()
+			if err := o.Commit(); err != nil {
+				t.Fatal(err)
+			}
+
+			header, err = o.Header("")
+			if err != nil || strings.Join(header, ",") != "a" {
+				t.Fatalf("%s : expected header a, got %v %v", compression, header, err)
+			}
+		}
+
+		if got, want := read(t, o, ""), "a\n1\n2\n"; got != want {
+			t.Errorf("%s : expected %q, got %q", compression, want, got)
+		}
+	}
+
+	o := GetOutWriter("orders.json", "orders.zip", &zerolog.Logger{})
+	o.Policy = ExistsAppend
+	if err := o.SetCompression("", -1); err == nil {
+		t.Error("no error for appending to a zip file")
+	}
+}
+
+func TestCompress(t *testing.T) {
+
+	tests := []struct {
+		out   string
+		level int
+		path  string
+	}{
+		{"orders.csv.gz", -1, "orders.csv.gz"},
+		{"orders.zip", 9, "orders.zip"},
+		{"orders.csv", 1, "orders.csv"},
+	}
+
+	rows := [][]string{{"id", "note"}, {"1", "a,b"}, {"2", strings.Repeat("x", 1<<16)}}
+	want := &strings.Builder{}
+	csv.NewWriter(want).WriteAll(rows)
+
+	for _, tt := range tests {
+
+		dir := t.TempDir()
+		o := GetOutWriter("orders.json", filepath.Join(dir, tt.out), &zerolog.Logger{})
+		if err := o.SetCompression("", tt.level); err != nil {
+			t.Fatal(err)
+		}
+
+		write(t, o, "", rows...)
+		write(t, o, "items", rows[:1]...)
+		o.Close()
+		if err := o.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := o.TablePath(""); got != filepath.Join(dir, tt.path) {
+			t.Errorf("%s : expected path %s, got %s", tt.out, tt.path, got)
+		}
+		if got := read(t, o, ""); got != want.String() {
+			t.Errorf("%s : round trip does not match, got %d bytes", tt.out, len(got))
+		}
+		if got := read(t, o, "items"); got != "id,note\n" {
+			t.Errorf("%s : expected the items header, got %q", tt.out, got)
+		}
+	}
+
+	o := GetOutWriter("orders.json", "orders.csv", &zerolog.Logger{})
+	for _, compression := range []string{CompressZstd, "lz4"} {
+		if err := o.SetCompression(compression, -1); err == nil {
+			t.Errorf("no error for compression %s", compression)
+		}
+	}
+	if err := o.SetCompression(CompressGzip, 10); err == nil {
+		t.Error("no error for compression level 10")
+	}
+}
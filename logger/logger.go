@@ -20,14 +20,16 @@ func GetLogger(verbose bool) *zerolog.Logger {
 
 }
 
-func SetFatalHook(logger *zerolog.Logger, outFile string, cleanup ...file.Close) *zerolog.Logger {
+// SetFatalHook returns a logger which runs the cleanup and removes the output files when fatal is logged.
+// outFiles is called at that point, so output files created later are removed as well.
+func SetFatalHook(logger *zerolog.Logger, outFiles func() []string, cleanup ...file.Close) *zerolog.Logger {
 
-	logger.Debug().Str("outFile", outFile).Msg("Setting up hook")
+	logger.Debug().Strs("outFiles", outFiles()).Msg("Setting up hook")
 
 	l := logger.Hook(FatalHook{
-		OutFile: outFile,
-		Logger:  logger,
-		cleanup: cleanup,
+		OutFiles: outFiles,
+		Logger:   logger,
+		cleanup:  cleanup,
 	})
 
 	return &l
@@ -35,9 +37,9 @@ func SetFatalHook(logger *zerolog.Logger, outFile string, cleanup ...file.Close)
 }
 
 type FatalHook struct {
-	cleanup []file.Close
-	OutFile string
-	Logger  *zerolog.Logger
+	cleanup  []file.Close
+	OutFiles func() []string
+	Logger   *zerolog.Logger
 }
 
 func (h FatalHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
@@ -47,12 +49,14 @@ func (h FatalHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
 			f()
 		}
 
-		err := os.Remove(h.OutFile)
-		if os.IsNotExist(err) {
-			return
-		}
-		if err != nil {
-			h.Logger.Debug().Err(err).Msg("error while removing out file")
+		for _, outFile := range h.OutFiles() {
+			err := os.Remove(outFile)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				h.Logger.Debug().Err(err).Msg("error while removing out file")
+			}
 		}
 	}
 }
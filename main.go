@@ -1,13 +1,18 @@
 package main
 
 import (
+	"bufio"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"runtime"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/akshaykhairmode/j2csv/converter"
@@ -19,18 +24,58 @@ import (
 )
 
 type flags struct {
-	inFile  string //the file to read for the json input
-	outFile string //the output file path
-	uts     string //unix to string
-	empty   string //fill empty columns with passed value
-	deli    string //delimeter to use
-	verbose bool   //enables debug logs
-	help    bool   //prints command help
-	stats   bool   //prints memory allocs/gc etc
-	force   bool   //will load the whole input file in memory
-	stdIn   bool   //get data from stdin
-	zip     bool   //create output in zip file
-	isArray bool   //if input is array of objects
+	inFile    string     //the file to read for the json input
+	outFile   string     //the output file path
+	uts       string     //unix to string
+	empty     string     //fill empty columns with passed value
+	deli      string     //delimeter to use
+	headers   string     //how to discover the headers. first, sample or all
+	sample    int        //number of records to scan for headers in sample mode
+	sep       string     //separator for flattened column names
+	depth     int        //max depth to flatten
+	explode   string     //array fields to write as one row per element
+	dialect   string     //input dialect, json or json5
+	root      string     //path of the records array inside the document
+	rootField string     //fields of the document to copy to every row
+	columns   string     //columns to write and their order
+	exclude   string     //columns to leave out
+	order     string     //order of the columns, source or sorted
+	decimals  int        //fixed number of decimals for numbers
+	sci       string     //scientific notation for numbers, on or off
+	thousands string     //thousands separator for numbers
+	timeFmt   string     //layout of the converted timestamps
+	tz        string     //time zone of the converted timestamps
+	layouts   stringList //layouts of the date strings in the timestamp columns
+	timeAllow string     //columns which are always converted by auto time
+	timeDeny  string     //columns which are never converted by auto time
+	autoTime  bool       //detect the timestamp columns
+	where     string     //expression which filters the records
+	derive    stringList //columns computed from the record
+	transform stringList //steps applied to the values of the columns
+	mask      stringList //columns to redact
+	maskRe    stringList //patterns to redact in every column
+	maskKey   string     //key of the hmac mask
+	onError   string     //what happens with records which can not be decoded, fail or skip
+	maxErrors int        //number of rejected records which stops the conversion
+	rejected  string     //path of the dead letter file
+	verbose   bool       //enables debug logs
+	help      bool       //prints command help
+	stats     bool       //prints memory allocs/gc etc
+	force     bool       //will load the whole input file in memory
+	stdIn     bool       //get data from stdin
+	zip       bool       //create output in zip file
+	compress  string     //compression of the output files, none, zip or gzip
+	level     int        //compression level
+	isArray   bool       //if input is array of objects
+	flatten   bool       //flatten nested objects into columns
+	normalize bool       //split nested arrays of objects into their own csv files
+	overwrite bool       //replace the output files if they exist
+	noClobber bool       //keep the output file if it exists and convert nothing
+	appendOut bool       //append the rows to the output files if they exist
+
+	ckptPath  string        //path of the checkpoint file
+	ckptEvery time.Duration //interval between the checkpoints
+	resume    bool          //resume the conversion from the checkpoint
 }
 
 const (
@@ -38,8 +83,23 @@ const (
 	colorGreen = "\033[32m"
 )
 
+// exitRejected is the exit code when the conversion finished but records were rejected.
+const exitRejected = 2
+
 var fg flags
 
+// stringList is a flag which can be passed more than once.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
 
 	startTime := time.Now()
@@ -47,81 +107,345 @@ func main() {
 	logWriter := logger.GetLogger(fg.verbose) //get a console logger
 	fg.printAll(logWriter)
 
-	input, closeInput := file.GetInputReader(fg.inFile, fg.stdIn, logWriter) //get a buffered reader from the input file.
+	if !converter.ValidDialect(fg.dialect) {
+		logWriter.Fatal().Msgf("unknown dialect %q, allowed values are %s and %s", fg.dialect, converter.JSON, converter.JSON5)
+	}
+
+	if fg.ckptPath != "" && fg.outFile == "" {
+		logWriter.Fatal().Msg("checkpoints need the output file, the default name changes on every run, usage --o /home/output.csv")
+	}
+
+	ck := checkpointing{save: parser.Checkpoint{Options: fg.options()}} //before the defaults which depend on other options are set.
+	if fg.resume {
+		ck.resume = loadCheckpoint(ck.save.Options, logWriter)
+	}
+
+	offset := int64(0)
+	if ck.resume != nil {
+		offset = ck.resume.Offset
+	}
+
+	input, closeInput := file.GetInputReader(fg.inFile, fg.stdIn, offset, logWriter) //get a buffered reader from the input file.
 	defer closeInput()
 
-	output, outFilePath, closeOutput := file.GetOutWriter(fg.inFile, fg.outFile, fg.zip, logWriter)
+	outputs := file.GetOutWriter(fg.inFile, fg.outFile, logWriter)
+	outputs.Policy = existsPolicy(logWriter)
+	if err := outputs.SetCompression(compression(logWriter), fg.level); err != nil {
+		logWriter.Fatal().Err(err).Msg("invalid compression")
+	}
+
+	if outputs.Compressed() && fg.ckptPath != "" {
+		logWriter.Fatal().Msg("checkpoints are not supported with compressed output, a compressed file can not be continued")
+	}
+
+	if outputs.Policy == file.ExistsNoClobber && outputs.Exists() && ck.resume == nil {
+		logWriter.Warn().Msgf("output file %s exists, nothing is written", outputs.TablePath(""))
+		return
+	}
+	if ck.resume != nil {
+		outputs.Resume(ck.resume.Outputs) //the files are continued at their size at the checkpoint.
+	}
 
 	if fg.deli != "" {
 		if len(fg.deli) > 1 {
 			logWriter.Fatal().Msg("Delimeter should be a single character")
 		}
-		output.Comma = rune(fg.deli[0])
+		outputs.Comma = rune(fg.deli[0])
 	}
 
-	logWriter = logger.SetFatalHook(logWriter, outFilePath, closeInput, closeOutput) //If fatal log level is called, delete the output file.
-
-	PrintMemUsage(fg.stats)
-	if fg.isArray {
-		processArray(output, input, logWriter, fg)
-	} else {
-		processObjects(output, input, logWriter, fg)
+	if fg.rejected == "" {
+		fg.rejected = outputs.DeadLetterPath()
 	}
-	PrintMemUsage(fg.stats)
+	deadLetter := file.NewLazyFile(fg.rejected, logWriter) //created only if a record is rejected.
 
-	closeOutput()
-	processZip(outFilePath, fg.zip, logWriter)
+	outFiles := outputs.Pending
+	if fg.ckptPath != "" {
+		outFiles = func() []string { return nil } //the output files are kept, the conversion can be resumed from the last checkpoint.
+	}
 
-	logWriter.Info().Msgf("Done!!, Time took : %v", time.Since(startTime))
+	logWriter = logger.SetFatalHook(logWriter, outFiles, closeInput, outputs.Close, deadLetter.Close) //If fatal log level is called, delete the part files.
 
-}
+	if ck.resume != nil {
+		if err := deadLetter.Resume(ck.resume.Outputs[deadLetter.Path]); err != nil {
+			logWriter.Fatal().Err(err).Msg("error while opening dead letter file")
+		}
+	}
 
-func processZip(outFilePath string, isZip bool, logWriter *zerolog.Logger) {
+	output, err := outputs.Writer("") //writer of the root table, the writers of normalized tables are created when needed.
+	if err != nil {
+		logWriter.Fatal().Err(err).Msg("error while creating output file")
+	}
 
-	format := func(s string) string {
-		return fmt.Sprintf("Output File ====> %v%s%v", colorGreen, s, colorReset)
+	mode := converter.Array
+	if ck.resume != nil {
+		mode = ck.resume.Mode
+	} else if !fg.isArray && fg.root == "" { //-a skips the detection, with root the records are always an array.
+		br := bufio.NewReaderSize(input, 1<<16)
+		mode = converter.Detect(br, fg.dialect)
+		input = br
+	}
+	logWriter.Info().Msgf("Input mode : %s", mode)
+
+	if fg.ckptPath != "" {
+		ck.save.InputSize, ck.save.Mode = inputSize(logWriter), mode
+		ck.outputs = func() (map[string]int64, error) {
+			sizes, err := outputs.Sizes()
+			if err != nil {
+				return nil, err
+			}
+			size, err := deadLetter.Size()
+			if size > 0 {
+				sizes[deadLetter.Path] = size
+			}
+			return sizes, err
+		}
 	}
 
-	if !isZip {
-		logWriter.Info().Msg(format(outFilePath))
-		return
+	var p processor
+	PrintMemUsage(fg.stats)
+	switch mode {
+	case converter.Array:
+		p = processArray(output, outputs, deadLetter, input, ck, logWriter, fg)
+	case converter.Lines:
+		p = processLines(output, outputs, deadLetter, input, ck, logWriter, fg)
+	default:
+		p = processObjects(output, outputs, deadLetter, input, ck, logWriter, fg)
 	}
+	PrintMemUsage(fg.stats)
+	deadLetter.Close()
 
-	zipPath, err := file.ZipFile(outFilePath, logWriter)
-	if err != nil {
-		logWriter.Error().Msg("could not create zip file")
-		os.Remove(zipPath)
-		return
+	outputs.Close()
+	if err := outputs.Commit(); err != nil { //the output files are replaced only now that the conversion succeeded.
+		logWriter.Fatal().Err(err).Msg("error while renaming output files")
 	}
+	for _, outFilePath := range outputs.Paths() {
+		logWriter.Info().Msgf("Output File ====> %v%s%v", colorGreen, outFilePath, colorReset)
+	}
+
+	logWriter.Info().Msgf("Done!!, Time took : %v", time.Since(startTime))
 
-	os.Remove(outFilePath)
-	logWriter.Info().Msgf(format(zipPath))
+	if fg.ckptPath != "" { //the conversion is complete, there is nothing to resume.
+		os.Remove(fg.ckptPath)
+	}
 
+	if rejected := p.Rejected(); rejected > 0 {
+		logWriter.Error().Msgf("%d records were rejected, see %s", rejected, deadLetter.Path)
+		os.Exit(exitRejected)
+	}
 }
 
-func processArray(output *csv.Writer, input io.Reader, logWriter *zerolog.Logger, fg flags) {
+func processArray(output *csv.Writer, outputs *file.Outputs, deadLetter io.Writer, input io.Reader, ck checkpointing, logWriter *zerolog.Logger, fg flags) processor {
+	input, tracker := converter.NewTracked(input, 0, fg.dialect, logWriter) //the comments are removed from JSON arrays as well, chunks without comments are passed as they are.
+
+	if ck.resume != nil { //the input starts after the last record of the checkpoint, inside the array.
+		tracker.Resume(ck.resume.Offset, ck.resume.Line, ck.resume.Column)
+		input = converter.ResumeArray(input, tracker)
+	}
 	decoder := json.NewDecoder(input)
-	p := parser.NewParser(output, decoder, logWriter).EnablePool().SetDefault(fg.empty)
+	p := newParser(output, outputs, decoder, input, tracker, deadLetter, ck, logWriter, fg)
 	p.ProcessArray(fg.uts)
+	return p
 }
 
-func processObjects(output *csv.Writer, input io.Reader, logWriter *zerolog.Logger, fg flags) {
+func processObjects(output *csv.Writer, outputs *file.Outputs, deadLetter io.Writer, input io.Reader, ck checkpointing, logWriter *zerolog.Logger, fg flags) processor {
 
 	var newInput io.Reader
+	var tracker *converter.Tracker
 
-	if fg.force {
-		newInput = converter.ConvertInMemory(input, logWriter)
+	if fg.force { //the offsets of the in memory conversion are not mapped.
+		newInput = converter.ConvertInMemory(input, fg.dialect, logWriter)
 	} else {
-		newInput = converter.New(input, 0, logWriter) //converter is the package name we are using.
+		newInput, tracker = converter.NewTracked(input, 0, fg.dialect, logWriter) //converter is the package name we are using.
+		if ck.resume != nil {
+			tracker.Resume(ck.resume.Offset, ck.resume.Line, ck.resume.Column)
+		}
 	}
 
 	decoder := json.NewDecoder(newInput)
-	p := parser.NewParser(output, decoder, logWriter).EnablePool().SetDefault(fg.empty)
+	p := newParser(output, outputs, decoder, newInput, tracker, deadLetter, ck, logWriter, fg)
 	p.ProcessObjects(fg.uts)
+	return p
+}
+
+func processLines(output *csv.Writer, outputs *file.Outputs, deadLetter io.Writer, input io.Reader, ck checkpointing, logWriter *zerolog.Logger, fg flags) processor {
+	input, tracker := converter.Track(input)
+	if ck.resume != nil {
+		tracker.Resume(ck.resume.Offset, ck.resume.Line, ck.resume.Column)
+	}
+	p := newParser(output, outputs, nil, nil, tracker, deadLetter, ck, logWriter, fg) //lines are decoded one by one, no decoder is needed.
+	p.ProcessLines(input, fg.uts)
+	return p
+}
+
+// inputName is the name of the input shown in the errors.
+func inputName(fg flags) string {
+	if fg.stdIn || fg.inFile == "" {
+		return "stdin"
+	}
+	return fg.inFile
+}
+
+// processor is the configured parser.
+type processor interface {
+	ProcessArray(uts string)
+	ProcessObjects(uts string)
+	ProcessLines(input io.Reader, uts string)
+	Rejected() int
+}
+
+// newParser returns a parser configured with the flags. input is the reader of the decoder, tracker maps its offsets back to the input file.
+func newParser(output *csv.Writer, outputs *file.Outputs, decoder *json.Decoder, input io.Reader, tracker *converter.Tracker, deadLetter io.Writer, ck checkpointing, logWriter *zerolog.Logger, fg flags) processor {
+	p := parser.NewParser(output, decoder, logWriter)
+	if tracker != nil { //a nil tracker would not be a nil locator.
+		p.SetLocator(inputName(fg), tracker)
+	}
+
+	var tables func(string) (*csv.Writer, error) //creates the writers of the child tables in normalize mode.
+	if outputs != nil {
+		tables = outputs.Writer
+		p.SetAppend(outputs.Header)
+	}
+
+	p.
+		EnablePool().
+		SetDefault(fg.empty).
+		SetHeaderMode(fg.headers, fg.sample).
+		SetFlatten(fg.flatten, fg.sep, fg.depth).
+		SetExplode(fg.explode).
+		SetNormalize(fg.normalize, tables).
+		SetRoot(fg.root, fg.rootField).
+		SetColumns(fg.columns, fg.exclude).
+		SetOrder(fg.order).
+		SetNumberFormat(fg.decimals, fg.sci, fg.thousands).
+		SetTimeFormat(fg.timeFmt, fg.tz).
+		SetInputLayouts(fg.layouts).
+		SetAutoTime(fg.autoTime, fg.timeAllow, fg.timeDeny).
+		SetDerive(fg.derive).
+		SetWhere(fg.where).
+		SetTransform(fg.transform).
+		SetMask(fg.mask, fg.maskRe, fg.maskKey).
+		SetOnError(fg.onError, fg.maxErrors, deadLetter, input)
+	if ck.resume != nil {
+		p.Resume(ck.resume)
+	}
+	return p.SetCheckpoint(fg.ckptPath, fg.ckptEvery, ck.save, ck.outputs)
+}
+
+// existsPolicy returns what happens when an output file exists.
+func existsPolicy(logWriter *zerolog.Logger) string {
+
+	policies := []string{}
+	if fg.overwrite {
+		policies = append(policies, file.ExistsOverwrite)
+	}
+	if fg.noClobber {
+		policies = append(policies, file.ExistsNoClobber)
+	}
+	if fg.appendOut {
+		policies = append(policies, file.ExistsAppend)
+	}
+
+	if len(policies) > 1 {
+		logWriter.Fatal().Msgf("only one of --overwrite, --no-clobber and --append can be used, got %s", strings.Join(policies, ", "))
+	}
+
+	if len(policies) <= 0 {
+		return file.ExistsError
+	}
+
+	return policies[0]
+}
+
+// compression returns the compression of the output files, empty to use the extension of the output path.
+func compression(logWriter *zerolog.Logger) string {
+
+	if !fg.zip {
+		return fg.compress
+	}
+
+	if fg.compress != "" && fg.compress != file.CompressZip {
+		logWriter.Fatal().Msgf("--z can not be used with --compress %s", fg.compress)
+	}
+
+	return file.CompressZip
+}
+
+// checkpointing is the checkpoint of the conversion and the checkpoint it is resumed from.
+type checkpointing struct {
+	save    parser.Checkpoint                //options, input size and mode of the conversion.
+	resume  *parser.Checkpoint               //nil for a new conversion.
+	outputs func() (map[string]int64, error) //syncs the output files and returns their sizes.
+}
+
+// loadCheckpoint loads the checkpoint to resume from. It should be of the same input and options.
+func loadCheckpoint(options map[string]string, logWriter *zerolog.Logger) *parser.Checkpoint {
+
+	if fg.ckptPath == "" {
+		logWriter.Fatal().Msg("resume needs the checkpoint file, usage --resume --checkpoint /home/orders.ckpt")
+	}
+
+	cp, err := parser.LoadCheckpoint(fg.ckptPath)
+	if err != nil {
+		logWriter.Fatal().Err(err).Msg("error while loading checkpoint")
+	}
+
+	changed := []string{}
+	for name, value := range options {
+		if cp.Options[name] != value {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	if len(changed) > 0 {
+		logWriter.Fatal().Strs("options", changed).Msg("the options are not the same as when the checkpoint was saved")
+	}
+
+	if size := inputSize(logWriter); size != cp.InputSize {
+		logWriter.Fatal().Int64("size", size).Int64("checkpoint", cp.InputSize).Msg("the input file changed since the checkpoint was saved")
+	}
+
+	return cp
+}
+
+// inputSize returns the size of the input file.
+func inputSize(logWriter *zerolog.Logger) int64 {
+
+	if fg.stdIn || fg.inFile == "" {
+		logWriter.Fatal().Msg("checkpoints need an input file, stdin can not be read again")
+	}
+
+	info, err := os.Stat(fg.inFile)
+	if err != nil {
+		logWriter.Fatal().Err(err).Msg("error while reading input file size")
+	}
+
+	return info.Size()
+}
+
+// options returns the options which change the output, a checkpoint is resumed only with the same options.
+func (f flags) options() map[string]string {
+
+	options := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		switch f.Name {
+		case "checkpoint", "checkpoint-interval", "resume", "v", "h", "stats":
+		case "mask-key": //the key is a secret, only its hash is saved.
+			sum := sha256.Sum256([]byte(f.Value.String()))
+			options[f.Name] = hex.EncodeToString(sum[:])
+		default:
+			options[f.Name] = f.Value.String()
+		}
+	})
+
+	return options
 }
 
 func (f flags) printAll(logger *zerolog.Logger) {
 	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name == "mask-key" && f.Value.String() != "" { //the key is a secret.
+			logger.Debug().Msgf("Flag %s , Value : %s", f.Name, "****")
+			return
+		}
 		logger.Debug().Msgf("Flag %s , Value : %s", f.Name, f.Value)
 	})
 }
@@ -130,16 +454,55 @@ func parseFlags() {
 	flag.BoolVar(&fg.stats, "stats", false, "prints the allocations at start and at end")
 	flag.StringVar(&fg.inFile, "f", "", "usage --f /home/input.txt (Required)")
 	flag.StringVar(&fg.outFile, "o", "", "usage --o /home/output.txt")
-	flag.StringVar(&fg.uts, "uts", "", "used to convert timestamp to string, usage --uts createdAt,updatedAt")
+	flag.StringVar(&fg.uts, "uts", "", "used to convert timestamp to string, usage --uts createdAt,updatedAt:ms. Units are s (default), ms, us, ns and auto")
+	flag.StringVar(&fg.timeFmt, "time-format", parser.FormatRFC3339, "layout of the converted timestamps. rfc3339, rfc3339nano, iso-date, iso-datetime, excel-serial, a strftime format like %d/%m/%Y or a Go layout like 02/01/2006")
+	flag.StringVar(&fg.tz, "tz", "UTC", "time zone of the converted timestamps, usage --tz Asia/Kolkata, --tz Local or --tz +05:30")
+	flag.Var(&fg.layouts, "layout", "layout of the date strings in the --uts columns, can be passed more than once. auto tries the common unambiguous formats, usage --layout auto --layout %d/%m/%Y")
+	flag.BoolVar(&fg.autoTime, "auto-time", false, "detect the timestamp columns from the values read while discovering the headers and convert them like --uts")
+	flag.StringVar(&fg.timeAllow, "time-allow", "", "columns which are always converted by --auto-time, names or globs. usage --time-allow *_at,born")
+	flag.StringVar(&fg.timeDeny, "time-deny", "", "columns which are never converted by --auto-time, names or globs. usage --time-deny id,version")
 	flag.StringVar(&fg.empty, "e", "", "usage --e NA, will put NA in columns where value does not exist")
 	flag.StringVar(&fg.deli, "d", "", `delimeter to use. usage --d ";", to use semicolon as delimeter`)
+	flag.StringVar(&fg.headers, "headers", parser.HeadersFirst, "how to discover the headers. first : keys of first record, sample : union of keys of first N records, all : union of keys of every record (two passes)")
+	flag.IntVar(&fg.sample, "sample", 100, "number of records to scan for headers when --headers is sample")
+	flag.StringVar(&fg.sep, "sep", ".", "separator used to join keys of flattened columns, usage --sep _")
+	flag.IntVar(&fg.depth, "depth", 0, "max depth of nested objects to flatten, 0 means no limit")
+	flag.StringVar(&fg.dialect, "dialect", converter.JSON, "input dialect, json or json5. json5 accepts trailing commas, single quoted strings, unquoted keys, hex numbers, Infinity and NaN")
+	flag.StringVar(&fg.root, "root", "", "path of the records array inside the document, usage --root /data/items or --root $.data.items")
+	flag.StringVar(&fg.rootField, "root-fields", "", "fields of the document to copy to every row, usage --root-fields meta.requestId. Only fields before the records array are found")
+	flag.StringVar(&fg.columns, "columns", "", "columns to write and their order, paths or globs. usage --columns id,user.id,items[*].sku,meta_*")
+	flag.StringVar(&fg.exclude, "exclude", "", "columns to leave out, names or globs. usage --exclude *_internal,password")
+	flag.StringVar(&fg.order, "order", parser.OrderSource, "order of the columns. source : order of the keys in the input, sorted : sorted by name")
+	flag.IntVar(&fg.decimals, "decimals", -1, "fixed number of decimals for numbers, -1 writes numbers as they are in the input")
+	flag.StringVar(&fg.sci, "sci", "", "scientific notation for numbers, on or off. By default numbers are written as they are in the input")
+	flag.StringVar(&fg.thousands, "thousands", "", `thousands separator for numbers, usage --thousands ","`)
+	flag.Var(&fg.derive, "derive", `column computed from the record, can be passed more than once. usage --derive 'full_name=fname + " " + lname' --derive 'born=year(createdAt)'`)
+	flag.Var(&fg.transform, "transform", `steps applied to the values of the column in order, can be passed more than once. usage --transform 'name=trim|upper' --transform 'price=scale(0.01)|round(2)'`)
+	flag.Var(&fg.mask, "mask", "redact the column, can be passed more than once. Modes are mask, partial(N), sha256 and hmac. usage --mask email=sha256 --mask 'phone=partial(4)'")
+	flag.Var(&fg.maskRe, "mask-pattern", `redact the matches of the regexp in every column, can be passed more than once. usage --mask-pattern '[\w.]+@[\w.]+=mask'`)
+	flag.StringVar(&fg.maskKey, "mask-key", os.Getenv("J2CSV_MASK_KEY"), "key of the hmac mask mode, J2CSV_MASK_KEY by default")
+	flag.StringVar(&fg.onError, "on-error", parser.OnErrorFail, "what happens with records which can not be decoded. fail : stop the conversion, skip : write them to the dead letter file and continue")
+	flag.IntVar(&fg.maxErrors, "max-errors", 0, "stop the conversion when more records are rejected with --on-error skip, 0 means no limit")
+	flag.StringVar(&fg.ckptPath, "checkpoint", "", "file where the state of the conversion is saved periodically, so that it can be continued with --resume if it stops")
+	flag.DurationVar(&fg.ckptEvery, "checkpoint-interval", time.Minute, "interval between the checkpoints")
+	flag.BoolVar(&fg.resume, "resume", false, "continue the conversion from the --checkpoint file, with the same options")
+	flag.StringVar(&fg.rejected, "dead-letter", "", "file of the records rejected with --on-error skip, one JSON object per line with offset, error and raw. Default is <output>_rejected.ndjson")
+	flag.StringVar(&fg.where, "where", "", `write only the records where the expression is true, usage --where 'status == "active" && age >= 18 && has(address.zip)'`)
+	flag.StringVar(&fg.explode, "explode", "", "write one row per element of the array fields, usage --explode items,orders.lines")
 
 	flag.BoolVar(&fg.verbose, "v", false, "Enables verbose logging")
 	flag.BoolVar(&fg.help, "h", false, "Prints command help")
-	flag.BoolVar(&fg.isArray, "a", false, "use this option if its an array of objects")
-	flag.BoolVar(&fg.force, "force", false, "force load input file in memory, use this if conversion is failing.")
+	flag.BoolVar(&fg.isArray, "a", false, "use this option if its an array of objects, skips the detection of the input mode")
+	flag.BoolVar(&fg.force, "force", false, "load the whole input file in memory instead of streaming it. Not needed for comments, kept for compatibility.")
 	flag.BoolVar(&fg.stdIn, "i", false, "get input data from standard input")
-	flag.BoolVar(&fg.zip, "z", false, "output file to be .zip")
+	flag.BoolVar(&fg.zip, "z", false, "output file to be .zip, same as --compress zip")
+	flag.StringVar(&fg.compress, "compress", "", "compression of the output files, none, zip or gzip. By default it is found from the extension of the output path, like .zip or .gz")
+	flag.IntVar(&fg.level, "compress-level", -1, "compression level from 1 (fastest) to 9 (smallest), 0 stores without compression and -1 is the default level")
+	flag.BoolVar(&fg.overwrite, "overwrite", false, "replace the output files if they exist. By default the conversion stops if they exist")
+	flag.BoolVar(&fg.noClobber, "no-clobber", false, "keep the output file if it exists, nothing is converted")
+	flag.BoolVar(&fg.appendOut, "append", false, "append the rows to the output files if they exist, the headers should be the same")
+	flag.BoolVar(&fg.normalize, "normalize", false, "write every nested array of objects to its own csv file, linked to the parent rows by _id, _parent_id and _ordinal columns")
+	flag.BoolVar(&fg.flatten, "flatten", false, "flatten nested objects into their own columns, {\"a\":{\"b\":1}} becomes column a.b")
 
 	flag.Parse()
 
@@ -9,6 +9,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/akshaykhairmode/j2csv/converter"
 	"github.com/rs/zerolog"
 )
 
@@ -89,7 +90,7 @@ func BenchmarkParseArray(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		inp := bytes.NewBuffer(dt)
 		out := bytes.NewBuffer(nil)
-		processArray(csv.NewWriter(out), inp, &zerolog.Logger{}, flags{})
+		processArray(csv.NewWriter(out), nil, nil, inp, checkpointing{}, &zerolog.Logger{}, flags{dialect: converter.JSON})
 		inp.Reset()
 		out.Reset()
 	}
@@ -110,7 +111,39 @@ func BenchmarkParseObjects(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		inp := bufio.NewReader(bytes.NewBuffer(dt))
 		out := bytes.NewBuffer(nil)
-		processObjects(csv.NewWriter(out), inp, &zerolog.Logger{}, flags{})
+		processObjects(csv.NewWriter(out), nil, nil, inp, checkpointing{}, &zerolog.Logger{}, flags{dialect: converter.JSON})
 		out.Reset()
 	}
 }
+
+func BenchmarkParseLines(b *testing.B) {
+
+	dt := bytes.NewBuffer(nil)
+	for i := 0; i < 5000; i++ { //the records of the array file, one per line.
+		fmt.Fprintf(dt, `{"fname":"John","Age":%d,"Location":"Australia","lname":"doe","createdAt":%d,"updatedAt":%d}`+"\n", i, 1672325049, 1672325049)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		inp := bytes.NewReader(dt.Bytes())
+		out := bytes.NewBuffer(nil)
+		processLines(csv.NewWriter(out), nil, nil, inp, checkpointing{}, &zerolog.Logger{}, flags{dialect: converter.JSON})
+	}
+}
+
+func TestProcessCommentedArray(t *testing.T) {
+
+	input := "// exported\n[{\"a\":1}, /* two */ {\"a\":2}]\n"
+
+	br := bufio.NewReader(bytes.NewBufferString(input))
+	if mode := converter.Detect(br, converter.JSON); mode != converter.Array {
+		t.Fatalf("expected mode %s, got %s", converter.Array, mode)
+	}
+
+	out := bytes.NewBuffer(nil)
+	processArray(csv.NewWriter(out), nil, nil, br, checkpointing{}, &zerolog.Logger{}, flags{dialect: converter.JSON})
+
+	if want := "a\n1\n2\n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
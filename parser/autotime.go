@@ -0,0 +1,246 @@
+package parser
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxTimeSamples is the number of values of every column which are checked by the auto time detection.
+const maxTimeSamples = 100
+
+// Plausible range of the detected timestamps, from the year 2000 to 2100.
+var (
+	minAutoTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxAutoTime = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// timeWords are the last words of the names of the columns whose numbers can be unix timestamps, like created, updatedAt, event_time or expires_on.
+// Numbers of other columns, like ids, are often in the range of the timestamps as well, they are converted only with --time-allow.
+var timeWords = map[string]bool{
+	"time": true, "date": true, "datetime": true, "timestamp": true, "stamp": true, "epoch": true, "ts": true, "at": true, "on": true,
+	"created": true, "updated": true, "modified": true, "deleted": true, "expires": true, "expiry": true, "expiration": true,
+}
+
+// unitWords are the units which can follow the time word, like created_at_ms.
+var unitWords = map[string]bool{"s": true, "sec": true, "secs": true, "seconds": true, "ms": true, "millis": true, "us": true, "micros": true, "ns": true, "nanos": true, "utc": true}
+
+// isTimeName reports if the last word of the column name, after a unit, is a time word. The name is split into words at the characters which are
+// not letters or digits and at the camelCase boundaries, so lastLoginTs is a time name and runtime, createdBy or candidate are not.
+func isTimeName(header string) bool {
+
+	words := splitWords(header)
+	if n := len(words); n > 1 && unitWords[words[n-1]] {
+		words = words[:n-1]
+	}
+
+	return len(words) > 0 && timeWords[words[len(words)-1]]
+}
+
+// splitWords splits the name into lower case words, HTTPTime is http and time.
+func splitWords(name string) []string {
+
+	runes := []rune(name)
+	words := []string{}
+	start := -1 //start of the current word.
+
+	for i, r := range runes {
+
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = -1
+			}
+			continue
+		}
+
+		if start < 0 {
+			start = i
+			continue
+		}
+
+		prev := runes[i-1]
+		if unicode.IsUpper(r) && (!unicode.IsUpper(prev) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) { //createdAt or HTTPTime.
+			words = append(words, strings.ToLower(string(runes[start:i])))
+			start = i
+		}
+	}
+
+	if start >= 0 {
+		words = append(words, strings.ToLower(string(runes[start:])))
+	}
+
+	return words
+}
+
+// autoTime detects the timestamp columns from the values read while discovering the headers, at least maxTimeSamples records are read.
+type autoTime struct {
+	enabled bool
+	allow   []*regexp.Regexp //columns which are always converted.
+	deny    []*regexp.Regexp //columns which are never converted.
+	samples map[string][]any //sampled values of every column.
+}
+
+// SetAutoTime enables the detection of timestamp columns. Columns with a time like name where every sampled value is a unix timestamp between
+// the years 2000 and 2100, or columns where every value is a date string, are converted like the -uts columns. allow and deny are comma
+// separated names or globs which override the detection.
+func (p *parser) SetAutoTime(enable bool, allow, deny string) *parser {
+	p.autoTime = autoTime{
+		enabled: enable,
+		allow:   compileGlobs(allow),
+		deny:    compileGlobs(deny),
+		samples: map[string][]any{},
+	}
+	return p
+}
+
+func compileGlobs(list string) []*regexp.Regexp {
+
+	globs := []*regexp.Regexp{}
+	for _, expr := range strings.Split(list, ",") {
+		if expr = strings.TrimSpace(expr); expr != "" {
+			globs = append(globs, compileGlob(expr))
+		}
+	}
+
+	return globs
+}
+
+func matchAny(globs []*regexp.Regexp, header string) bool {
+	for _, glob := range globs {
+		if glob.MatchString(header) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleTimes keeps the scalar values of the row for the detection.
+func (p *parser) sampleTimes(r row) {
+
+	if !p.autoTime.enabled {
+		return
+	}
+
+	for key, value := range r.values {
+		switch value.(type) {
+		case json.Number, float64, string, bool, map[string]any, []any:
+		default:
+			continue //nulls say nothing about the column.
+		}
+		if samples := p.autoTime.samples[key]; len(samples) < maxTimeSamples {
+			p.autoTime.samples[key] = append(samples, value)
+		}
+	}
+}
+
+// detectTimes adds the detected timestamp columns to the -uts columns. Columns passed in -uts are not changed.
+func (p *parser) detectTimes(headerMap map[string]any) {
+
+	if !p.autoTime.enabled {
+		return
+	}
+
+	headers := make([]string, 0, len(headerMap))
+	for header := range headerMap {
+		headers = append(headers, header)
+	}
+	sort.Strings(headers)
+
+	dates := false //if a column of date strings was detected.
+
+	for _, header := range headers {
+
+		if isGenerated(header) {
+			continue
+		}
+
+		if _, ok := p.utsHeaders[header]; ok {
+			p.logger.Info().Str("column", header).Msg("auto time : passed in --uts, converted as passed")
+			continue
+		}
+
+		if matchAny(p.autoTime.deny, header) {
+			p.logger.Info().Str("column", header).Msg("auto time : denied, not converted")
+			continue
+		}
+
+		if matchAny(p.autoTime.allow, header) {
+			p.logger.Info().Str("column", header).Msg("auto time : allowed, converted")
+			p.utsHeaders[header] = UnitAuto
+			dates = true
+			continue
+		}
+
+		kind, reason := p.timeKind(header, p.autoTime.samples[header])
+		if kind == "" {
+			p.logger.Info().Str("column", header).Str("reason", reason).Msg("auto time : not converted")
+			continue
+		}
+
+		p.logger.Info().Str("column", header).Str("kind", kind).Int("samples", len(p.autoTime.samples[header])).Msg("auto time : converted")
+		p.utsHeaders[header] = UnitAuto
+		dates = dates || kind == "date string"
+	}
+
+	if dates && len(p.layouts) <= 0 {
+		p.layouts = autoLayouts //date strings are read with the common formats if no layout was passed.
+	}
+
+	p.autoTime.samples = nil
+}
+
+// timeKind returns the kind of timestamps of the sampled values of the column, epoch or date string, or the reason why they are not timestamps.
+func (p *parser) timeKind(header string, samples []any) (string, string) {
+
+	if len(samples) <= 0 {
+		return "", "no values"
+	}
+
+	layouts := p.layouts
+	if len(layouts) <= 0 {
+		layouts = autoLayouts
+	}
+
+	kind := ""
+	for _, sample := range samples {
+
+		var text, sampleKind string
+		switch v := sample.(type) {
+		case json.Number:
+			text, sampleKind = v.String(), "epoch"
+		case float64:
+			text, sampleKind = strconv.FormatFloat(v, 'f', -1, 64), "epoch"
+		case string:
+			text, sampleKind = v, "date string"
+		default:
+			return "", "not a number or a string"
+		}
+
+		if kind != "" && kind != sampleKind {
+			return "", "mixed numbers and strings"
+		}
+		kind = sampleKind
+
+		if kind == "epoch" {
+			if t, ok := epoch(text, UnitAuto); !ok || t.Before(minAutoTime) || !t.Before(maxAutoTime) {
+				return "", "number " + text + " is not a plausible timestamp"
+			}
+			continue
+		}
+
+		if _, ok := parseIn(layouts, text, time.UTC); !ok {
+			return "", "string " + strconv.Quote(text) + " is not a date"
+		}
+	}
+
+	if kind == "epoch" && !isTimeName(header) {
+		return "", "the name is not a time name, use --time-allow to convert its numbers"
+	}
+
+	return kind, ""
+}
@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checkpoint is the state of a conversion after a record, written periodically so that the conversion can be resumed after it.
+type Checkpoint struct {
+	Options   map[string]string `json:"options"`   //options of the conversion, set by the caller. A conversion is resumed with the same options.
+	InputSize int64             `json:"inputSize"` //size of the input file, set by the caller.
+	Mode      string            `json:"mode"`      //input mode, set by the caller.
+	Offset    int64             `json:"offset"`    //offset in the input after the last record.
+	Line      int               `json:"line"`      //line of the offset, 0 if it is not known.
+	Column    int               `json:"column"`    //column of the offset.
+	Records   int64             `json:"records"`   //records read from the input.
+	Rows      int64             `json:"rows"`      //rows written to the output files.
+	Rejected  int               `json:"rejected"`  //records rejected in skip mode.
+	Outputs   map[string]int64  `json:"outputs"`   //sizes of the output files, by path.
+	Tables    []checkpointTable `json:"tables"`    //header state of the output tables.
+	IDs       map[string]int64  `json:"ids,omitempty"`
+	Ranks     map[string]int    `json:"ranks,omitempty"`
+	UTS       map[string]string `json:"uts,omitempty"` //timestamp columns, including the detected ones.
+	Layouts   []string          `json:"layouts,omitempty"`
+	Root      map[string]any    `json:"root,omitempty"` //values of the root fields.
+	Saved     time.Time         `json:"saved"`
+	outputs   func() (map[string]int64, error)
+	path      string
+	interval  time.Duration
+	last      time.Time
+}
+
+type checkpointTable struct {
+	Name    string              `json:"name"`
+	Headers []string            `json:"headers"`
+	Paths   map[string][]string `json:"paths,omitempty"`
+}
+
+// LoadCheckpoint reads the checkpoint file.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &Checkpoint{}
+	if err := unmarshal(data, cp); err != nil { //numbers of the root fields are kept as they are.
+		return nil, fmt.Errorf("invalid checkpoint file %s : %w", path, err)
+	}
+
+	return cp, nil
+}
+
+// SetCheckpoint writes the state of the conversion to path every interval, after the record being written. cp has the options, the input size
+// and the mode of the conversion, the parser adds its state. outputs flushes the output files to disk and returns their sizes.
+func (p *parser) SetCheckpoint(path string, interval time.Duration, cp Checkpoint, outputs func() (map[string]int64, error)) *parser {
+
+	if path == "" {
+		return p
+	}
+
+	if p.headerMode == HeadersAll {
+		p.logger.Fatal().Msgf("checkpoints are not supported with the %s header mode, the records are written in a second pass", HeadersAll)
+	}
+
+	if p.locator == nil {
+		p.logger.Fatal().Msg("checkpoints need the offsets of the input, they are not known with the in memory conversion")
+	}
+
+	if interval <= 0 {
+		p.logger.Fatal().Msgf("checkpoint interval should be greater than 0, got : %v", interval)
+	}
+
+	cp.path, cp.interval, cp.outputs, cp.last = path, interval, outputs, time.Now()
+	p.checkpoint = &cp
+	return p
+}
+
+// Resume restores the state of the checkpoint. The input should be read from the offset of the checkpoint and the output files
+// should be continued, the headers are not discovered and written again.
+func (p *parser) Resume(cp *Checkpoint) *parser {
+
+	p.resumed = cp
+	p.records, p.written = cp.Records, cp.Rows
+	p.rejects.count = cp.Rejected
+
+	for k, v := range cp.IDs {
+		p.ids[k] = v
+	}
+	for k, v := range cp.Ranks {
+		p.ranks[k] = v
+	}
+	for k, v := range cp.UTS {
+		p.utsHeaders[k] = v
+	}
+	if len(cp.Layouts) > 0 {
+		p.layouts = cp.Layouts
+	}
+
+	for _, field := range p.rootFields {
+		field.value, field.found = cp.Root[field.name]
+	}
+
+	p.logger.Info().Int64("offset", cp.Offset).Int64("records", cp.Records).Int64("rows", cp.Rows).Msgf("resuming from the checkpoint of %v", cp.Saved.Format(time.RFC3339))
+
+	return p
+}
+
+// restoreHeaders sets the headers of the tables of the checkpoint, their header rows are written already.
+func (p *parser) restoreHeaders() {
+
+	for _, ct := range p.resumed.Tables {
+
+		t := p.table(ct.Name)
+		t.headers, t.keys, t.ready = ct.Headers, nil, true
+		if ct.Paths != nil {
+			t.paths = ct.Paths
+		}
+
+		if t.name == "" {
+			t.out = p.out
+		} else {
+			t.out = p.tableWriter(t.name)
+		}
+	}
+
+	p.pool.SetPools(len(p.table("").headers))
+}
+
+// saveCheckpoint writes the checkpoint if the interval has passed since the last one. It is called after a record is written.
+func (p *parser) saveCheckpoint() {
+
+	cp := p.checkpoint
+	if cp == nil || time.Since(cp.last) < cp.interval {
+		return
+	}
+
+	p.flush()
+
+	loc := p.locate(p.src.InputOffset())
+	cp.Offset, cp.Line, cp.Column = loc.Offset, loc.Line, loc.Column
+	cp.Records, cp.Rows, cp.Rejected = p.records, p.written, p.rejects.count
+	outputs, err := cp.outputs()
+	if err != nil {
+		p.logger.Fatal().Err(err).Msg("error while syncing output files")
+	}
+	cp.Outputs = outputs
+	cp.IDs, cp.Ranks, cp.UTS, cp.Layouts = p.ids, p.ranks, p.utsHeaders, p.layouts
+
+	cp.Tables = cp.Tables[:0]
+	for _, t := range p.tableOrder {
+		if t.ready {
+			cp.Tables = append(cp.Tables, checkpointTable{Name: t.name, Headers: t.headers, Paths: t.paths})
+		}
+	}
+
+	cp.Root = map[string]any{}
+	for _, field := range p.rootFields {
+		if field.found {
+			cp.Root[field.name] = field.value
+		}
+	}
+
+	cp.Saved = time.Now()
+
+	if err := writeFileAtomic(cp.path, cp); err != nil {
+		p.logger.Fatal().Err(err).Msg("error while writing checkpoint file")
+	}
+
+	cp.last = cp.Saved
+	p.logger.Debug().Int64("offset", cp.Offset).Int64("records", cp.Records).Msg("checkpoint saved")
+}
+
+// writeFileAtomic writes v as JSON to a temporary file and renames it to path, so that a crash never leaves half a checkpoint.
+func writeFileAtomic(path string, v any) error {
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fh.Name()) //fails once renamed.
+
+	if _, err := fh.Write(data); err != nil {
+		fh.Close()
+		return err
+	}
+
+	if err := fh.Sync(); err != nil {
+		fh.Close()
+		return err
+	}
+
+	if err := fh.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(fh.Name(), path)
+}
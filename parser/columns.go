@@ -0,0 +1,246 @@
+package parser
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// column is a --columns or --exclude expression. Globs match the column names, paths are looked up in the row.
+type column struct {
+	expr string
+	path []string       //keys of the path, * matches every element of an array. Nil for globs.
+	glob *regexp.Regexp //set if the expression has a * or ? outside of [*].
+}
+
+// SetColumns sets the columns to write and their order, and the columns to leave out.
+// Both are comma separated lists of paths, like user.id or items[*].sku, or globs, like *_internal.
+// The columns are only applied to the root table, the excluded columns are removed from every table.
+func (p *parser) SetColumns(columns, exclude string) *parser {
+	p.columns = p.parseColumns(columns)
+	p.exclude = p.parseColumns(exclude)
+	return p
+}
+
+func (p *parser) parseColumns(list string) []column {
+
+	columns := []column{}
+
+	for _, expr := range strings.Split(list, ",") {
+
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+
+		if isGlob(expr) {
+			columns = append(columns, column{expr: expr, glob: compileGlob(expr)})
+			continue
+		}
+
+		path, err := parsePath(expr)
+		if err == nil {
+			for _, key := range path {
+				if key == "" {
+					err = strconv.ErrSyntax
+				}
+			}
+		}
+		if err != nil || len(path) <= 0 || strings.HasPrefix(expr, "/") {
+			p.logger.Fatal().Msgf("invalid column expression %q, usage user.id, items[*].sku or *_internal", expr)
+		}
+
+		columns = append(columns, column{expr: expr, path: path})
+	}
+
+	return columns
+}
+
+// isGlob reports if the expression has a * or ? outside of the [*] array wildcard.
+func isGlob(expr string) bool {
+	return strings.ContainsAny(strings.ReplaceAll(expr, "[*]", ""), "*?")
+}
+
+// compileGlob compiles the glob to a regexp. * matches any characters, ? matches one character and [*] matches any array index.
+func compileGlob(expr string) *regexp.Regexp {
+
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case strings.HasPrefix(expr[i:], "[*]"):
+			b.WriteString(`\[\d+\]`)
+			i += 2
+		case expr[i] == '*':
+			b.WriteString(".*")
+		case expr[i] == '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(expr[i : i+1]))
+		}
+	}
+
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// project applies the columns and the excluded columns to the discovered headers of the table.
+// Like setUTS, a path which does not match any header is fatal.
+func (p *parser) project(t *table) {
+
+	headers := t.headers
+
+	if t.name == "" && len(p.columns) > 0 {
+
+		keys := make(map[string]struct{}, len(headers))
+		for _, header := range headers {
+			keys[header] = struct{}{}
+		}
+
+		selected := []string{}
+		seen := map[string]struct{}{}
+		add := func(header string) {
+			if _, ok := seen[header]; !ok {
+				seen[header] = struct{}{}
+				selected = append(selected, header)
+			}
+		}
+
+		for _, header := range headers { //generated columns are needed to join the tables.
+			if isGenerated(header) {
+				add(header)
+			}
+		}
+
+		for _, c := range p.columns {
+
+			if c.glob != nil {
+				matched := false
+				for _, header := range headers {
+					if c.glob.MatchString(header) {
+						add(header)
+						matched = true
+					}
+				}
+				if !matched {
+					p.logger.Warn().Str("column", c.expr).Msg("column pattern does not match any header")
+				}
+				continue
+			}
+
+			if _, ok := keys[c.expr]; !ok {
+				if !p.pathInHeaders(keys, c.path) {
+					p.logger.Fatal().Msgf("Passed column %v does not match with file headers : %v", c.expr, headers)
+				}
+				t.paths[c.expr] = c.path //not a key of the row, the value is looked up by path.
+			}
+
+			add(c.expr)
+		}
+
+		headers = selected
+	}
+
+	if len(p.exclude) > 0 {
+		kept := make([]string, 0, len(headers))
+		for _, header := range headers {
+			if !p.excluded(header) {
+				kept = append(kept, header)
+			}
+		}
+		headers = kept
+	}
+
+	t.headers = headers
+}
+
+func (p *parser) excluded(header string) bool {
+	for _, c := range p.exclude {
+		if p.matches(c, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports if the header is the column. The keys of a path are joined by sep like the flattened headers.
+func (p *parser) matches(c column, header string) bool {
+	if c.glob != nil {
+		return c.glob.MatchString(header)
+	}
+	return c.expr == header || strings.Join(c.path, p.sep) == header
+}
+
+// checkExcluded validates the excluded columns against the discovered headers of every table. Like the columns, a path which does
+// not match any header is fatal. In normalize mode it is a warning, the tables found later are not known yet.
+func (p *parser) checkExcluded(headerMap map[string]any) {
+
+	for _, c := range p.exclude {
+
+		matched := false
+		for header := range headerMap {
+			if p.matches(c, header) {
+				matched = true
+				break
+			}
+		}
+
+		switch {
+		case matched:
+		case c.glob != nil || p.normalize:
+			p.logger.Warn().Str("column", c.expr).Msg("excluded column does not match any header")
+		default:
+			headers := make([]string, 0, len(headerMap))
+			for header := range headerMap {
+				headers = append(headers, header)
+			}
+			sort.Strings(headers)
+			p.logger.Fatal().Msgf("Excluded column %v does not match with file headers : %v", c.expr, headers)
+		}
+	}
+}
+
+// pathInHeaders reports if the path starts with one of the headers, so that its value can be looked up in the rows.
+func (p *parser) pathInHeaders(keys map[string]struct{}, path []string) bool {
+	for i := len(path); i > 0; i-- {
+		if _, ok := keys[strings.Join(path[:i], p.sep)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the value at path. The keys of flattened rows are joined by sep, so the longest joined key is tried first.
+// A * key returns the values of every element of the array.
+func lookup(v any, path []string, sep string) (any, bool) {
+
+	if len(path) <= 0 {
+		return v, true
+	}
+
+	switch c := v.(type) {
+	case map[string]any:
+		for i := len(path); i > 0; i-- {
+			if next, ok := c[strings.Join(path[:i], sep)]; ok {
+				return lookup(next, path[i:], sep)
+			}
+		}
+	case []any:
+		if path[0] == "*" {
+			values := make([]any, 0, len(c))
+			for _, element := range c {
+				if value, ok := lookup(element, path[1:], sep); ok {
+					values = append(values, value)
+				}
+			}
+			return values, true
+		}
+		if i, err := strconv.Atoi(path[0]); err == nil && i >= 0 && i < len(c) {
+			return lookup(c[i], path[1:], sep)
+		}
+	}
+
+	return nil, false
+}
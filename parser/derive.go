@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"strings"
+	"time"
+
+	"github.com/akshaykhairmode/j2csv/expr"
+)
+
+// derived is a column computed from the record.
+type derived struct {
+	name string
+	expr *expr.Expr
+}
+
+// SetDerive sets the columns computed from the record. Every column is name=expression, like total=qty * price or born=year(createdAt).
+// The columns are added to the record in the passed order before it is filtered, exploded or flattened, so an expression can use the columns before it.
+func (p *parser) SetDerive(columns []string) *parser {
+
+	p.derived = nil
+
+	for _, column := range columns {
+
+		name, src, ok := strings.Cut(column, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			p.logger.Fatal().Msgf("invalid derived column %q, usage --derive 'total=qty * price'", column)
+		}
+
+		e, err := expr.Compile(src, p.exprFuncs())
+		if err != nil {
+			p.logger.Fatal().Msgf("invalid expression of derived column %s : %v", name, err)
+		}
+
+		p.derived = append(p.derived, derived{name: name, expr: e})
+	}
+
+	return p
+}
+
+// derive adds the derived columns to the record.
+func (p *parser) derive(object map[string]any) {
+	for _, d := range p.derived {
+		object[d.name] = d.expr.Eval(object)
+	}
+}
+
+// deriveRank returns the rank of the derived column in source order. Derived columns come after the columns of the input in the passed order.
+func (p *parser) deriveRank(header string) (int, bool) {
+	for i, d := range p.derived {
+		if d.name == header {
+			return len(p.ranks) + 1 + i, true
+		}
+	}
+	return 0, false
+}
+
+// exprFuncs are the date functions of the expressions. Numbers are unix timestamps of any unit, strings are read with the -layout layouts or the common formats.
+// The parts of the date are in the zone of -tz.
+func (p *parser) exprFuncs() map[string]expr.Func {
+
+	part := func(get func(t time.Time) int) expr.Func {
+		return expr.Func{Min: 1, Max: 1, Call: func(args []any) any {
+			t, ok := p.toTime(args[0])
+			if !ok {
+				return nil
+			}
+			return get(t.In(p.times.loc))
+		}}
+	}
+
+	return map[string]expr.Func{
+		"year":   part(func(t time.Time) int { return t.Year() }),
+		"month":  part(func(t time.Time) int { return int(t.Month()) }),
+		"day":    part(func(t time.Time) int { return t.Day() }),
+		"hour":   part(func(t time.Time) int { return t.Hour() }),
+		"minute": part(func(t time.Time) int { return t.Minute() }),
+		"second": part(func(t time.Time) int { return t.Second() }),
+		"date": {Min: 1, Max: 1, Call: func(args []any) any { //written like the -uts columns.
+			t, ok := p.toTime(args[0])
+			if !ok {
+				return nil
+			}
+			return p.times.format(t)
+		}},
+		"format": {Min: 2, Max: 2, Call: func(args []any) any { //format(createdAt, "%Y-%m")
+			t, ok := p.toTime(args[0])
+			if !ok {
+				return nil
+			}
+			layout, err := parseLayout(expr.String(args[1]))
+			if err != nil {
+				return nil
+			}
+			return t.In(p.times.loc).Format(layout)
+		}},
+	}
+}
+
+// toTime converts the unix timestamp or the date string to time.
+func (p *parser) toTime(v any) (time.Time, bool) {
+
+	if v == nil {
+		return time.Time{}, false
+	}
+
+	text := expr.String(v)
+	if t, ok := epoch(text, UnitAuto); ok {
+		return t, true
+	}
+
+	layouts := p.layouts
+	if len(layouts) <= 0 {
+		layouts = autoLayouts
+	}
+
+	return parseIn(layouts, text, p.times.loc)
+}
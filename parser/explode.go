@@ -0,0 +1,149 @@
+package parser
+
+import "strings"
+
+// SetExplode sets the array fields which are written as one row per element, like $unwind of mongodb.
+// paths is a comma separated list of dotted paths, for example items,orders.lines. Arrays found in the middle of a path are exploded as well.
+// The exploded fields are flattened even without flatten, so that the elements are written as columns.
+func (p *parser) SetExplode(paths string) *parser {
+
+	for _, path := range strings.Split(paths, ",") {
+
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		keys := strings.Split(path, ".")
+		for _, key := range keys {
+			if key == "" {
+				p.logger.Fatal().Msgf("invalid explode path : %q", path)
+			}
+		}
+
+		p.explode = append(p.explode, keys)
+	}
+
+	return p
+}
+
+// rows returns the rows to be written for the decoded object, one row per element of every exploded array.
+// In normalize mode the rows of the nested arrays of objects are returned as well.
+func (p *parser) rows(object map[string]any) []row {
+
+	p.addRootFields(object)
+	p.derive(object)
+
+	if p.where != nil && !p.where.Match(object) {
+		return nil //filtered out.
+	}
+
+	rows := []map[string]any{object}
+
+	for _, path := range p.explode {
+		exploded := make([]map[string]any, 0, len(rows))
+		for _, row := range rows {
+			exploded = explodePath(exploded, row, path)
+		}
+		rows = exploded
+	}
+
+	out := make([]row, 0, len(rows))
+	for _, values := range rows {
+		values = p.flattenExploded(values)
+		if p.normalize {
+			out = p.normalizeRow(out, "", values, 0, 0)
+			continue
+		}
+		out = append(out, row{values: p.flatten(values)})
+	}
+
+	return out
+}
+
+// flattenExploded returns the row with the objects of the exploded fields flattened, when the rows are not flattened already.
+// The other nested fields are kept as JSON.
+func (p *parser) flattenExploded(values map[string]any) map[string]any {
+
+	if p.flat || len(p.explode) <= 0 {
+		return values
+	}
+
+	var cp map[string]any
+	for _, path := range p.explode {
+
+		nested, ok := values[path[0]].(map[string]any)
+		if !ok || len(nested) <= 0 {
+			continue
+		}
+
+		if cp == nil { //the row can be the decoded object, which is not changed.
+			cp = make(map[string]any, len(values))
+			for k, v := range values {
+				cp[k] = v
+			}
+		}
+
+		delete(cp, path[0])
+		p.flattenInto(cp, path[0], nested, 1)
+	}
+
+	if cp == nil {
+		return values
+	}
+
+	return cp
+}
+
+// explodePath appends to out a copy of row for every element of the array at path.
+// The parent fields are repeated in every copy. If the field is missing or the array is empty the parent row is still written once.
+func explodePath(out []map[string]any, row map[string]any, path []string) []map[string]any {
+
+	value, ok := row[path[0]]
+	if !ok {
+		return append(out, row)
+	}
+
+	elements, isArray := value.([]any)
+	if !isArray {
+		if nested, ok := value.(map[string]any); ok && len(path) > 1 { //Not an array, but the array could be deeper inside this object.
+			for _, sub := range explodePath(nil, nested, path[1:]) {
+				out = append(out, with(row, path[0], sub))
+			}
+			return out
+		}
+		return append(out, row)
+	}
+
+	if len(elements) <= 0 { //Keep the parent row, the array field is dropped so that its columns are empty.
+		cp := with(row, path[0], nil)
+		delete(cp, path[0])
+		return append(out, cp)
+	}
+
+	for _, element := range elements {
+
+		if nested, ok := element.(map[string]any); ok && len(path) > 1 {
+			for _, sub := range explodePath(nil, nested, path[1:]) {
+				out = append(out, with(row, path[0], sub))
+			}
+			continue
+		}
+
+		out = append(out, with(row, path[0], element))
+	}
+
+	return out
+}
+
+// with returns a shallow copy of row where key is set to value.
+func with(row map[string]any, key string, value any) map[string]any {
+
+	cp := make(map[string]any, len(row))
+	for k, v := range row {
+		cp[k] = v
+	}
+	cp[key] = value
+
+	return cp
+}
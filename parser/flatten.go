@@ -0,0 +1,49 @@
+package parser
+
+// SetFlatten enables flattening of nested objects into their own columns, named by joining the keys with sep.
+// Objects nested deeper than depth are kept as JSON, 0 means there is no limit.
+func (p *parser) SetFlatten(enable bool, sep string, depth int) *parser {
+
+	if enable && sep == "" {
+		p.logger.Fatal().Msg("flatten separator cannot be empty")
+	}
+
+	if depth < 0 {
+		p.logger.Fatal().Msgf("flatten depth cannot be negative, got : %d", depth)
+	}
+
+	p.flat = enable
+	p.sep = sep
+	p.depth = depth
+	return p
+}
+
+// flatten returns the row for the decoded object. If flattening is disabled the object itself is the row.
+func (p *parser) flatten(object map[string]any) map[string]any {
+
+	if !p.flat {
+		return object
+	}
+
+	row := make(map[string]any, p.pool.length)
+	p.flattenInto(row, "", object, 1)
+	return row
+}
+
+func (p *parser) flattenInto(row map[string]any, prefix string, object map[string]any, depth int) {
+
+	for key, value := range object {
+
+		if prefix != "" {
+			key = prefix + p.sep + key
+		}
+
+		nested, ok := value.(map[string]any)
+		if !ok || len(nested) <= 0 || (p.depth > 0 && depth > p.depth) { //Empty objects and objects beyond max depth are written as JSON.
+			row[key] = value
+			continue
+		}
+
+		p.flattenInto(row, key, nested, depth+1)
+	}
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// Header discovery modes.
+const (
+	HeadersFirst  = "first"  //headers are the keys of the first record.
+	HeadersSample = "sample" //headers are the union of keys of the first N records.
+	HeadersAll    = "all"    //headers are the union of keys of every record. Needs two passes over the input.
+)
+
+// SetHeaderMode sets how the headers are discovered. sample is the number of records to scan in sample mode.
+func (p *parser) SetHeaderMode(mode string, sample int) *parser {
+
+	switch mode {
+	case "", HeadersFirst:
+		mode = HeadersFirst
+	case HeadersSample:
+		if sample <= 0 {
+			p.logger.Fatal().Msgf("sample size should be greater than 0, got : %d", sample)
+		}
+	case HeadersAll:
+	default:
+		p.logger.Fatal().Msgf("unknown header mode %q, allowed values are %s, %s and %s", mode, HeadersFirst, HeadersSample, HeadersAll)
+	}
+
+	p.headerMode = mode
+	p.sampleSize = sample
+	return p
+}
+
+// discoverHeaders reads records from the source till the header mode is satisfied and adds their keys to the headers of their tables.
+// The rows which were read are returned so that they can be written after the headers. With auto time more records are read for its samples.
+// In all mode no rows are returned, every record is spilled to a temporary file instead which is replayed later by replaySpill.
+func (p *parser) discoverHeaders() []row {
+
+	rows := []row{}
+
+	limit := 1
+	if p.headerMode == HeadersSample {
+		limit = p.sampleSize
+	}
+
+	read := 0  //number of records read.
+	count := 0 //number of records read which were not filtered out or rejected.
+
+	var spill *bufio.Writer
+	if p.headerMode == HeadersAll {
+		p.spill, spill = p.createSpill()
+	}
+
+	samples := 0 //records read for the auto time detection only, their keys are not added to the headers.
+	if p.autoTime.enabled && spill == nil {
+		samples = maxTimeSamples
+	}
+
+	for p.src.More() && (spill != nil || count < limit || count < samples) {
+
+		read++
+
+		object := map[string]any{}
+		raw, ok := p.nextRecord(object, spill != nil, true) //Decode the object into map and rank its keys, the raw record is needed for the spill file.
+		if !ok {
+			continue
+		}
+
+		objectRows := p.rows(object) //Headers are built from the exploded and flattened rows.
+		if len(objectRows) <= 0 {
+			continue //filtered out by the where expression, the headers are built from the written records only.
+		}
+
+		for _, r := range objectRows {
+			if spill != nil || count < limit {
+				p.addKeys(r)
+			}
+			p.sampleTimes(r)
+		}
+		count++
+
+		if spill == nil {
+			rows = append(rows, objectRows...)
+			continue
+		}
+
+		spill.Write(raw) //In all mode we keep the raw record in the spill file, one record per line.
+		if err := spill.WriteByte('\n'); err != nil {
+			p.logger.Fatal().Err(err).Msg("error while writing to spill file")
+		}
+	}
+
+	if spill != nil {
+		if err := spill.Flush(); err != nil {
+			p.logger.Fatal().Err(err).Msg("error while writing to spill file")
+		}
+	}
+
+	p.records += int64(read)
+
+	if read <= 0 {
+		p.logger.Fatal().Msgf("empty object") //If we dont get first object the the file would not have one and could be an empty array.
+	}
+
+	if count <= 0 {
+		p.logger.Warn().Int("records", read).Msg("no record to write, every record was filtered out by the where expression or rejected")
+	}
+
+	p.logger.Debug().Str("mode", p.headerMode).Int("tables", len(p.tableOrder)).Msg("discovered headers")
+
+	return rows
+}
+
+func (p *parser) createSpill() (*os.File, *bufio.Writer) {
+	fh, err := os.CreateTemp("", "j2csv-spill-*.ndjson")
+	if err != nil {
+		p.logger.Fatal().Err(err).Msg("error while creating spill file")
+	}
+
+	p.logger.Debug().Str("path", fh.Name()).Msg("spilling records for header discovery")
+
+	return fh, bufio.NewWriter(fh)
+}
+
+// replaySpill is the second pass of the all mode. It writes every record which was spilled while discovering the headers.
+func (p *parser) replaySpill() {
+
+	if p.spill == nil {
+		return
+	}
+
+	defer func() {
+		p.spill.Close()
+		os.Remove(p.spill.Name())
+		p.spill = nil
+	}()
+
+	if _, err := p.spill.Seek(0, io.SeekStart); err != nil {
+		p.logger.Fatal().Err(err).Msg("error while rewinding spill file")
+	}
+
+	p.src = newLineSource(p.spill) //Every spilled record is on its own line.
+	p.ids = map[string]int64{}     //The ids generated in the first pass are discarded.
+	p.parseArrayElements()
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/akshaykhairmode/j2csv/converter"
+	"github.com/rs/zerolog"
+)
+
+// locator maps the offsets of the decoded stream back to the input file, the converter removes comments and changes JSON5 to JSON.
+type locator interface {
+	Locate(offset int64) converter.Location
+}
+
+// SetLocator sets the name of the input and the locator of its offsets, they are used to report the line and column of the errors.
+// Without a locator the offsets of the decoded stream are reported.
+func (p *parser) SetLocator(name string, l locator) *parser {
+	p.inputName = name
+	p.locator = l
+	return p
+}
+
+// locate returns the location in the input of the offset of the decoded stream.
+func (p *parser) locate(offset int64) converter.Location {
+	if p.locator == nil {
+		return converter.Location{Offset: offset}
+	}
+	return p.locator.Locate(offset)
+}
+
+// errorOffset returns the offset of the byte with the syntax error, for other errors the offset of the record is returned.
+// The offset of a syntax error is the offset after the bad byte, counted from the start of what the decoder read.
+func (p *parser) errorOffset(offset int64, err error) int64 {
+
+	var syntax *json.SyntaxError
+	if !errors.As(err, &syntax) || syntax.Offset <= 0 {
+		return offset
+	}
+
+	switch s := p.src.(type) {
+	case *lineSource:
+		return s.start + syntax.Offset - 1
+	case offsetSource:
+		return s.base + syntax.Offset - 1
+	}
+
+	return syntax.Offset - 1
+}
+
+// logError logs the error at the offset of the decoded stream with its location in the input, the line is shown with a marker under the column.
+func (p *parser) logError(e *zerolog.Event, at int64, err error, msg string) {
+
+	loc := p.locate(at)
+
+	e = e.Int64("offset", loc.Offset)
+	if loc.Line > 0 {
+		e = e.Int("line", loc.Line).Int("column", loc.Column)
+	}
+
+	where := loc.String()
+	if p.inputName != "" {
+		where = p.inputName + " " + where
+	}
+
+	if loc.Snippet != "" {
+		e.Msgf("%s at %s : %v\n%s", msg, where, err, loc.Snippet)
+		return
+	}
+
+	e.Msgf("%s at %s : %v", msg, where, err)
+}
@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/akshaykhairmode/j2csv/expr"
+)
+
+// Masking modes.
+const (
+	MaskFull    = "mask"    //replaces the value with ****.
+	MaskPartial = "partial" //keeps the last characters, partial(4) is the default.
+	MaskSHA256  = "sha256"  //hex sha-256 of the value.
+	MaskHMAC    = "hmac"    //hex hmac-sha-256 of the value with the mask key, the same value and key give the same pseudonym.
+)
+
+// fullMask is written for fully masked values, the length of the value is not kept.
+const fullMask = "****"
+
+// masking redacts the values of the matching columns, or the parts of every value matching the pattern.
+type masking struct {
+	glob    *regexp.Regexp //columns to mask, nil for patterns.
+	pattern *regexp.Regexp //parts of the values to mask, nil for columns.
+	mask    func(string) string
+}
+
+// SetMask sets the masked columns and patterns. columns are column=mode, like email=sha256, the column can be a glob.
+// patterns are regexp=mode, like [0-9]{10}=partial(4), and mask the matches in the columns which are not masked. key is the key of the hmac mode.
+// Masking is the last step before the values are written, after the transforms.
+func (p *parser) SetMask(columns, patterns []string, key string) *parser {
+
+	p.masks = nil
+	p.masked = map[string][]masking{}
+
+	add := func(list []string, column bool) {
+		for _, m := range list {
+
+			i := strings.LastIndex(m, "=") //patterns can have =, modes can not.
+			if i <= 0 {
+				p.logger.Fatal().Msgf("invalid mask %q, usage --mask email=sha256 or --mask-pattern '[0-9]{10}=partial(4)'", m)
+			}
+			target, mode := strings.TrimSpace(m[:i]), m[i+1:]
+
+			calls, err := expr.ParsePipeline(mode)
+			if err == nil && len(calls) != 1 {
+				err = &expr.Error{Expr: mode, Pos: calls[1].Pos, Msg: "expected one mode"}
+			}
+			if err != nil {
+				p.logger.Fatal().Msgf("invalid mask of %s : %v", target, err)
+			}
+
+			mask, err := newMask(calls[0], key)
+			if err != nil {
+				p.logger.Fatal().Msgf("invalid mask of %s : %v", target, &expr.Error{Expr: mode, Pos: calls[0].Pos, Msg: err.Error()})
+			}
+
+			if column {
+				p.masks = append(p.masks, masking{glob: compileGlob(target), mask: mask})
+				continue
+			}
+
+			re, err := regexp.Compile(target)
+			if err != nil {
+				p.logger.Fatal().Msgf("invalid mask pattern %q : %v", target, err)
+			}
+			p.masks = append(p.masks, masking{pattern: re, mask: mask})
+		}
+	}
+
+	add(columns, true)
+	add(patterns, false)
+
+	return p
+}
+
+// maskValue masks the value of the column. Empty values are not masked.
+func (p *parser) maskValue(header, value string) string {
+
+	if len(p.masks) <= 0 || value == "" {
+		return value
+	}
+
+	masks, ok := p.masked[header]
+	if !ok { //the matching masks are cached for every column. Masked columns are redacted already, the patterns are for the other columns.
+		patterns := []masking{}
+		for _, m := range p.masks {
+			switch {
+			case m.pattern != nil:
+				patterns = append(patterns, m)
+			case m.glob.MatchString(header):
+				masks = append(masks, m)
+			}
+		}
+		if len(masks) <= 0 {
+			masks = patterns
+		}
+		p.masked[header] = masks
+	}
+
+	for _, m := range masks {
+		if m.pattern != nil {
+			value = m.pattern.ReplaceAllStringFunc(value, m.mask)
+			continue
+		}
+		value = m.mask(value)
+	}
+
+	return value
+}
+
+func newMask(call expr.Call, key string) (func(string) string, error) {
+
+	switch call.Name {
+	case MaskFull:
+		return func(string) string { return fullMask }, checkArgs(call, 0, 0)
+	case MaskPartial:
+		if err := checkArgs(call, 0, 1); err != nil {
+			return nil, err
+		}
+		keep := 4
+		if len(call.Args) > 0 {
+			var err error
+			if keep, err = intArg(call.Args[0]); err != nil || keep < 0 {
+				return nil, fmt.Errorf("partial needs the number of characters to keep, usage partial(4)")
+			}
+		}
+		return func(s string) string {
+			n := utf8.RuneCountInString(s) - keep
+			if n <= 0 { //short values are masked fully, else they would be written as they are.
+				return strings.Repeat("*", utf8.RuneCountInString(s))
+			}
+			runes := []rune(s)
+			return strings.Repeat("*", n) + string(runes[n:])
+		}, nil
+	case MaskSHA256:
+		return func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}, checkArgs(call, 0, 0)
+	case MaskHMAC:
+		if key == "" {
+			return nil, fmt.Errorf("hmac needs a key, pass --mask-key or set J2CSV_MASK_KEY")
+		}
+		return func(s string) string {
+			mac := hmac.New(sha256.New, []byte(key))
+			mac.Write([]byte(s))
+			return hex.EncodeToString(mac.Sum(nil))
+		}, checkArgs(call, 0, 0)
+	}
+
+	return nil, fmt.Errorf("unknown mask mode %s, allowed values are %s, %s, %s and %s", call.Name, MaskFull, MaskPartial, MaskSHA256, MaskHMAC)
+}
@@ -0,0 +1,115 @@
+package parser
+
+import "encoding/csv"
+
+// Columns generated in normalize mode to join the tables back together.
+const (
+	idColumn      = "_id"        //Id of the row, unique in its table.
+	parentColumn  = "_parent_id" //Id of the parent row.
+	ordinalColumn = "_ordinal"   //Position of the row in the parent array, starts from 1.
+)
+
+var generatedColumns = []string{idColumn, parentColumn, ordinalColumn}
+
+// tableSep joins the keys of the path of a child table, like items.parts. It does not change with --sep, the file names are made from it.
+const tableSep = "."
+
+func isGenerated(key string) bool {
+	return key == idColumn || key == parentColumn || key == ordinalColumn
+}
+
+// SetNormalize enables the relational split of nested arrays of objects. Every array goes to its own table whose writer is created by newWriter.
+// Every row gets an _id column and the rows of child tables get _parent_id and _ordinal columns.
+func (p *parser) SetNormalize(enable bool, newWriter func(table string) (*csv.Writer, error)) *parser {
+
+	if enable && newWriter == nil {
+		p.logger.Fatal().Msg("normalize needs a writer for the child tables")
+	}
+
+	p.normalize = enable
+	p.newWriter = newWriter
+	return p
+}
+
+// array is a nested array of objects found in a row, path is relative to the row.
+type array struct {
+	path     string
+	elements []any
+}
+
+// normalizeRow appends the row for values to out, followed by the rows of its nested arrays of objects.
+func (p *parser) normalizeRow(out []row, name string, values map[string]any, parentID int64, ordinal int) []row {
+
+	p.ids[name]++
+	id := p.ids[name]
+
+	arrays := []array{}
+	parent := splitArrays(values, "", &arrays)
+
+	parent[idColumn] = id
+	if name != "" {
+		parent[parentColumn] = parentID
+		parent[ordinalColumn] = ordinal
+	}
+
+	out = append(out, row{table: name, values: p.flatten(parent)})
+
+	for _, a := range arrays {
+
+		child := a.path
+		if name != "" {
+			child = name + tableSep + a.path
+		}
+
+		for i, element := range a.elements {
+			out = p.normalizeRow(out, child, element.(map[string]any), id, i+1)
+		}
+	}
+
+	return out
+}
+
+// splitArrays returns a copy of values without the arrays of objects, which are appended to arrays instead. Nested objects are searched as well.
+func splitArrays(values map[string]any, prefix string, arrays *[]array) map[string]any {
+
+	cp := make(map[string]any, len(values))
+
+	for key, value := range values {
+
+		path := key
+		if prefix != "" {
+			path = prefix + tableSep + key
+		}
+
+		switch v := value.(type) {
+		case map[string]any:
+			cp[key] = splitArrays(v, path, arrays)
+		case []any:
+			if isObjectArray(v) {
+				*arrays = append(*arrays, array{path: path, elements: v})
+				continue
+			}
+			cp[key] = v
+		default:
+			cp[key] = v
+		}
+	}
+
+	return cp
+}
+
+// isObjectArray reports if every element of the array is an object. Empty arrays are not object arrays.
+func isObjectArray(elements []any) bool {
+
+	if len(elements) <= 0 {
+		return false
+	}
+
+	for _, element := range elements {
+		if _, ok := element.(map[string]any); !ok {
+			return false
+		}
+	}
+
+	return true
+}
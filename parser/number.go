@@ -0,0 +1,266 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Scientific notation settings.
+const (
+	SciKeep = ""    //numbers are written as they are in the input.
+	SciOn   = "on"  //numbers are written in scientific notation.
+	SciOff  = "off" //exponents are expanded, 1e3 is written as 1000.
+)
+
+const maxExponent = 1000 //numbers with bigger exponents are always written as they are, expanding them would write thousands of zeros.
+
+// numberFormat is the opt-in formatting of numbers. The zero value writes every number exactly as it is in the input.
+type numberFormat struct {
+	decimals  int    //fixed number of decimals, -1 keeps the decimals of the input.
+	sci       string //scientific notation, SciKeep, SciOn or SciOff.
+	thousands string //separator between the groups of thousands, empty for none.
+}
+
+// SetNumberFormat sets how numbers are written. decimals is the fixed number of decimals, -1 keeps the decimals of the input.
+// sci is on, off or empty to keep the notation of the input. thousands is the separator between the groups of thousands.
+func (p *parser) SetNumberFormat(decimals int, sci, thousands string) *parser {
+
+	if sci != SciKeep && sci != SciOn && sci != SciOff {
+		p.logger.Fatal().Msgf("unknown scientific notation %q, allowed values are %s and %s", sci, SciOn, SciOff)
+	}
+
+	if decimals < -1 {
+		p.logger.Fatal().Msgf("decimals should be -1 or more, got : %d", decimals)
+	}
+
+	p.numbers = numberFormat{decimals: decimals, sci: sci, thousands: thousands}
+	return p
+}
+
+// unmarshal decodes data into v like json.Unmarshal, but numbers are decoded as json.Number so that they are not rounded.
+func unmarshal(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// formatNumber formats the number text with the number format.
+func (nf numberFormat) formatNumber(text string) string {
+
+	if nf.decimals < 0 && nf.sci == SciKeep && nf.thousands == "" {
+		return text
+	}
+
+	d, ok := parseDecimal(text)
+	if !ok || d.exp > maxExponent || d.exp < -maxExponent {
+		return text
+	}
+
+	if nf.sci == SciOn {
+		return d.scientific(nf.decimals)
+	}
+
+	if nf.sci == SciKeep && nf.decimals < 0 && strings.ContainsAny(text, "eE") { //only thousands are set, keep the notation of the input.
+		return text
+	}
+
+	if nf.decimals >= 0 {
+		d = d.round(-nf.decimals)
+	}
+
+	return d.plain(nf.decimals, nf.thousands)
+}
+
+// decimal is an exact decimal number, its value is digits * 10^exp.
+type decimal struct {
+	neg    bool
+	digits string //digits without leading zeros, empty for zero.
+	exp    int
+}
+
+func parseDecimal(text string) (decimal, bool) {
+
+	var d decimal
+
+	s := text
+	if strings.HasPrefix(s, "-") {
+		d.neg = true
+		s = s[1:]
+	}
+
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return d, false
+		}
+		d.exp = exp
+		s = s[:i]
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+
+	digits := intPart + fracPart
+	if digits == "" {
+		return d, false
+	}
+
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return d, false
+		}
+	}
+
+	d.digits = strings.TrimLeft(digits, "0")
+	d.exp -= len(fracPart)
+
+	return d, true
+}
+
+// round rounds the number to exp, half away from zero. round(-2) keeps 2 decimals.
+func (d decimal) round(exp int) decimal {
+
+	drop := exp - d.exp
+	if drop <= 0 {
+		return d
+	}
+
+	keep := len(d.digits) - drop
+	roundUp := false
+	switch {
+	case keep >= 0 && keep < len(d.digits):
+		roundUp = d.digits[keep] >= '5'
+	case keep < 0:
+		keep = 0
+	}
+
+	digits := []byte(d.digits[:keep])
+	if roundUp {
+		i := len(digits) - 1
+		for ; i >= 0 && digits[i] == '9'; i-- {
+			digits[i] = '0'
+		}
+		if i >= 0 {
+			digits[i]++
+		} else {
+			digits = append([]byte{'1'}, digits...)
+		}
+	}
+
+	d.digits = strings.TrimLeft(string(digits), "0")
+	d.exp = exp
+	return d
+}
+
+// plain writes the number without exponent, with at least decimals decimals and the thousands separator.
+func (d decimal) plain(decimals int, thousands string) string {
+
+	digits := d.digits
+	if d.exp > 0 {
+		digits += strings.Repeat("0", d.exp)
+	}
+
+	frac := 0
+	if d.exp < 0 {
+		frac = -d.exp
+	}
+
+	if len(digits) <= frac {
+		digits = strings.Repeat("0", frac-len(digits)+1) + digits
+	}
+
+	intPart, fracPart := digits[:len(digits)-frac], digits[len(digits)-frac:]
+	if decimals > len(fracPart) {
+		fracPart += strings.Repeat("0", decimals-len(fracPart))
+	}
+
+	if thousands != "" {
+		intPart = groupThousands(intPart, thousands)
+	}
+
+	var b strings.Builder
+	if d.neg && strings.Trim(d.digits, "0") != "" {
+		b.WriteByte('-')
+	}
+	b.WriteString(intPart)
+	if fracPart != "" {
+		b.WriteByte('.')
+		b.WriteString(fracPart)
+	}
+
+	return b.String()
+}
+
+// scientific writes the number as d.ddde+XX. decimals is the number of decimals of the mantissa, -1 writes every digit.
+func (d decimal) scientific(decimals int) string {
+
+	if d.digits == "" {
+		d.digits, d.exp = "0", 0
+	}
+
+	exp := d.exp + len(d.digits) - 1 //exponent of the first digit.
+
+	if decimals >= 0 {
+		r := d.round(exp - decimals)
+		if len(r.digits) > decimals+1 { //rounding added a digit, 9.99 to 10.0
+			r = r.round(r.exp + 1)
+		}
+		d = r
+		if d.digits == "" {
+			d.digits = "0"
+		}
+		exp = d.exp + len(d.digits) - 1
+		d.digits += strings.Repeat("0", decimals+1-len(d.digits))
+	}
+
+	var b strings.Builder
+	if d.neg {
+		b.WriteByte('-')
+	}
+	b.WriteByte(d.digits[0])
+	if len(d.digits) > 1 {
+		b.WriteByte('.')
+		b.WriteString(d.digits[1:])
+	}
+
+	b.WriteByte('e')
+	if exp < 0 {
+		b.WriteByte('-')
+		exp = -exp
+	} else {
+		b.WriteByte('+')
+	}
+	if exp < 10 {
+		b.WriteByte('0')
+	}
+	b.WriteString(strconv.Itoa(exp))
+
+	return b.String()
+}
+
+// groupThousands inserts sep between every group of three digits.
+func groupThousands(digits, sep string) string {
+
+	if len(digits) <= 3 {
+		return digits
+	}
+
+	var b strings.Builder
+	first := len(digits) % 3
+	if first > 0 {
+		b.WriteString(digits[:first])
+	}
+
+	for i := first; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+
+	return b.String()
+}
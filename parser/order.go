@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+)
+
+// Header orders.
+const (
+	OrderSource = "source" //columns in the order the keys were first seen in the input.
+	OrderSorted = "sorted" //columns sorted by name.
+)
+
+// SetOrder sets the order of the columns.
+func (p *parser) SetOrder(order string) *parser {
+
+	switch order {
+	case "", OrderSource:
+		order = OrderSource
+	case OrderSorted:
+	default:
+		p.logger.Fatal().Msgf("unknown order %q, allowed values are %s and %s", order, OrderSource, OrderSorted)
+	}
+
+	p.order = order
+	return p
+}
+
+// orderHeaders orders the headers of the table. In source order the headers seen in the input come first in that order,
+// followed by the other headers, like the root fields, sorted, and the derived columns. The generated columns of normalize mode always come first.
+func (p *parser) orderHeaders(t *table) []string {
+
+	headers := sortHeaders(t.keys)
+	if p.order != OrderSource {
+		return headers
+	}
+
+	prefix := ""
+	if t.name != "" { //the ranks are paths joined by sep like the headers.
+		prefix = strings.ReplaceAll(t.name, tableSep, p.sep) + p.sep
+	}
+
+	rank := func(header string) int {
+		if isGenerated(header) {
+			return -1
+		}
+		if r, ok := p.ranks[prefix+header]; ok {
+			return r
+		}
+		if r, ok := p.deriveRank(header); ok && t.name == "" {
+			return r
+		}
+		return len(p.ranks) //not seen in the input, keeps the sorted order.
+	}
+
+	sort.SliceStable(headers, func(i, j int) bool {
+		return rank(headers[i]) < rank(headers[j])
+	})
+
+	return headers
+}
@@ -5,20 +5,60 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"sort"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/akshaykhairmode/j2csv/expr"
 	"github.com/rs/zerolog"
 )
 
 type parser struct {
-	headers    []string //headers will be stored here.
-	defaults   string
-	out        *csv.Writer         //Our output file will be csv
-	decoder    *json.Decoder       //This is the json decoder we will use.
-	utsHeaders map[string]struct{} //The columns which needs conversion from UNIX to string.
-	logger     zerolog.Logger      //We will use the console logger of zerolog.
-	pool       *pool               //To reduce some load on the GC.
+	tables      map[string]*table //Output tables by name, the root table has an empty name.
+	tableOrder  []*table          //Tables in the order they were found.
+	defaults    string
+	out         *csv.Writer       //Our output file will be csv, this is the writer of the root table.
+	decoder     *json.Decoder     //This is the json decoder we will use.
+	src         source            //The records are read from here, the decoder itself or the lines of newline delimited JSON.
+	utsHeaders  map[string]string //The columns which needs conversion from UNIX to string and their units.
+	logger      zerolog.Logger    //We will use the console logger of zerolog.
+	pool        *pool             //To reduce some load on the GC.
+	headerMode  string            //How the headers are discovered. first, sample or all.
+	sampleSize  int               //Number of records to scan for headers in sample mode.
+	spill       *os.File          //In all mode, records are spilled here during the first pass and replayed in the second.
+	flat        bool              //Should nested objects be flattened into their own columns.
+	sep         string            //Separator used to join the keys of flattened columns.
+	depth       int               //Max depth to flatten, 0 means no limit.
+	explode     [][]string        //Paths of the arrays which are written as one row per element.
+	normalize   bool              //Should nested arrays of objects be split into their own tables.
+	newWriter   func(string) (*csv.Writer, error)
+	existing    func(string) ([]string, error)   //Header rows of the output files which are appended to.
+	ids         map[string]int64                 //Last generated id of every table in normalize mode.
+	root        []string                         //Path of the records array inside the document, empty if the document is the array.
+	rootFields  []*rootField                     //Fields of the document copied to every row.
+	columns     []column                         //Columns to write and their order, empty writes every header.
+	exclude     []column                         //Columns to leave out.
+	order       string                           //Order of the columns, source or sorted.
+	ranks       map[string]int                   //Position of every key path in the input, used for the source order.
+	numbers     numberFormat                     //How numbers are written, by default exactly as in the input.
+	times       timeFormat                       //How the converted timestamps are written.
+	layouts     []string                         //Go layouts of the date strings in the timestamp columns.
+	autoTime    autoTime                         //Detection of the timestamp columns.
+	where       *expr.Expr                       //Filter of the records, nil writes every record.
+	derived     []derived                        //Columns computed from the record.
+	transforms  []transform                      //Steps applied to the values of the columns.
+	transformed map[string][]func(string) string //Steps of every column, cached.
+	missing     map[string]string                //Text of the missing values of every column, cached.
+	masks       []masking                        //Redaction of the values.
+	masked      map[string][]masking             //Masks of every column, cached.
+	rejects     rejects                          //Records which could not be decoded.
+	array       bool                             //If the records are the elements of an array.
+	inputName   string                           //Name of the input, shown in the errors.
+	locator     locator                          //Maps the offsets of the decoded stream back to the input, nil if they are the same.
+	checkpoint  *Checkpoint                      //State written periodically, nil if checkpoints are disabled.
+	resumed     *Checkpoint                      //Checkpoint the conversion is resumed from, nil for a new conversion.
+	records     int64                            //Records read from the input.
+	written     int64                            //Rows written to the output files, without the header rows.
 }
 
 func (p *parser) EnablePool() *parser {
@@ -33,19 +73,39 @@ func (p *parser) SetDefault(d string) *parser {
 
 func NewParser(out *csv.Writer, decoder *json.Decoder, logger *zerolog.Logger) *parser {
 
+	if decoder != nil {
+		decoder.UseNumber() //Decode numbers as json.Number so that big ids and decimals are written as they are.
+	}
+
 	return &parser{
+		tables:     map[string]*table{},
+		ids:        map[string]int64{},
 		out:        out,
 		decoder:    decoder,
-		utsHeaders: map[string]struct{}{},
+		src:        decoder,
+		utsHeaders: map[string]string{},
 		logger:     *logger,
 		pool:       &pool{},
 		defaults:   "",
+		headerMode: HeadersFirst,
+		sep:        ".",
+		order:      OrderSource,
+		ranks:      map[string]int{},
+		numbers:    numberFormat{decimals: -1},
+		times:      timeFormat{layout: time.RFC3339, loc: time.UTC},
 	}
 }
 
 func (p *parser) ProcessArray(uts string) {
 
-	p.startToken()
+	p.array = true
+
+	if len(p.root) > 0 && p.resumed == nil { //when resumed the input starts inside the records array.
+		p.seekRoot(p.root, nil)
+		p.logMissingRootFields()
+	} else {
+		p.startToken()
+	}
 
 	p.setHeadersAndWriteFirstRow(uts, true)
 
@@ -53,6 +113,8 @@ func (p *parser) ProcessArray(uts string) {
 
 	p.endToken()
 
+	p.replaySpill()
+
 }
 
 func (p *parser) ProcessObjects(uts string) {
@@ -61,17 +123,39 @@ func (p *parser) ProcessObjects(uts string) {
 
 	p.parseArrayElements()
 
+	p.replaySpill()
+
+}
+
+// ProcessLines processes newline delimited JSON, one record per line.
+func (p *parser) ProcessLines(input io.Reader, uts string) {
+
+	p.src = newLineSource(input)
+
+	p.setHeadersAndWriteFirstRow(uts, false)
+
+	p.parseArrayElements()
+
+	p.replaySpill()
+
 }
 
-func (p *parser) writeRow(row map[string]any, isFirstRow bool) {
+func (p *parser) writeRow(t *table, row map[string]any, isFirstRow bool) {
 
 	csvRow := p.pool.GetStringSlice() //get string slice from pool.
 
-	for _, header := range p.headers { //We will loop on every header and get the value for that header. Since we are looping on headers we will skip extra elements which could be there in later objects
+	for _, header := range t.headers { //We will loop on every header and get the value for that header. Since we are looping on headers we will skip extra elements which could be there in later objects
 
-		value := row[header]
+		value, ok := row[header]
+		if path := t.paths[header]; !ok && path != nil && !isFirstRow {
+			value, _ = lookup(row, path, p.sep)
+		}
 
 		if value == nil {
+			if !isFirstRow {
+				csvRow = append(csvRow, p.missingValue(header)) //per column defaults are set with the default transform, the placeholder is not masked.
+				continue
+			}
 			csvRow = append(csvRow, p.defaults)
 			continue
 		}
@@ -81,69 +165,65 @@ func (p *parser) writeRow(row map[string]any, isFirstRow bool) {
 			continue
 		}
 
-		csvRow = append(csvRow, p.parseRowValue(header, value)) //get the proper value after conversion.
+		csvRow = append(csvRow, p.maskValue(header, p.transformValue(header, p.parseRowValue(header, value)))) //get the proper value after conversion.
 	}
 
-	p.out.Write(csvRow)           //Write to our csv writer.
+	if !isFirstRow {
+		p.written++
+	}
+
+	t.out.Write(csvRow)           //Write to our csv writer.
 	p.pool.PutStringSlice(csvRow) //put the slice back in pool.
 }
 
 func (p *parser) parseArrayElements() {
 
-	defer p.out.Flush()
+	defer p.flush()
 
-	for p.decoder.More() {
+	for p.src.More() {
 
+		p.records++
 		object := p.pool.GetMapStringAny()
 
-		if err := p.decoder.Decode(&object); err != nil {
-			data, _ := io.ReadAll(p.decoder.Buffered())
-			p.logger.Fatal().Int64("offset", p.decoder.InputOffset()).Bytes("data", data).Msgf("error while parseArrayElements decoding object : %v", err)
+		if _, ok := p.nextRecord(object, false, p.normalize); !ok { //tables found later need the key order as well.
+			p.pool.PutMapStringAny(object)
+			continue
 		}
 
-		p.writeRow(object, false)
+		p.write(p.rows(object))
 		p.pool.PutMapStringAny(object)
+		p.saveCheckpoint()
 	}
 
 }
 
-func (p *parser) getHeaderAndFirstRow() ([]string, map[string]any) {
-	if p.decoder.More() { //Check if we have an object
-		object := map[string]any{}
-		if err := p.decoder.Decode(&object); err != nil { //Decode the object into map.
-			p.logger.Fatal().Msgf("error while decoding object : %v", err)
-		}
-
-		headers := []string{}
-		for key := range object {
-			headers = append(headers, key)
-		}
-
-		sort.Strings(headers) //Sort headers or we will get random order every run because maps & json being unordered.
+func (p *parser) setHeadersAndWriteFirstRow(uts string, isArray bool) {
 
-		return headers, object
+	if p.resumed != nil { //the headers were written before the checkpoint.
+		p.restoreHeaders()
+		return
 	}
 
-	p.logger.Fatal().Msgf("empty object") //If we dont get first object the the file would not have one and could be an empty array.
-
-	return nil, nil
-}
+	headerMap := map[string]any{}
 
-func (p *parser) setHeadersAndWriteFirstRow(uts string, isArray bool) {
+	rows := p.discoverHeaders()
 
-	headerMap := map[string]any{}
+	for _, t := range p.tableOrder {
+		for key := range t.keys {
+			headerMap[key] = key //Union of the headers of every table, used for fast lookups.
+		}
+	}
 
-	headers, row := p.getHeaderAndFirstRow()
+	p.pool.SetPools(len(p.table("").keys)) //set pool as we now know the header size
+	p.setUTS(uts, headerMap)               //set uts so that later we can use this to convert the unix timestamp to string.
+	p.detectTimes(headerMap)               //add the detected timestamp columns to the uts columns.
+	p.checkExcluded(headerMap)
 
-	p.headers = headers
-	for _, header := range headers {
-		headerMap[header] = header //We are using map because we want to write this as first row itself and our writeRow method only takes map. Also this helps with fast lookups.
+	for _, t := range p.tableOrder { //Write the headers to csv files.
+		p.writeHeaders(t)
 	}
 
-	p.pool.SetPools(len(headers)) //set pool as we now know the header size
-	p.setUTS(uts, headerMap)      //set uts so that later we can use this to convert the unix timestamp to string.
-	p.writeRow(headerMap, true)   //Write the headers to csv file.
-	p.writeRow(row, false)        //Write our first row after headers.
+	p.write(rows) //Write the rows we have read while discovering the headers.
 }
 
 func (p *parser) endToken() {
@@ -157,9 +237,10 @@ func (p *parser) startToken() {
 }
 
 func (p *parser) token() json.Token {
+	offset := p.src.InputOffset()
 	token, err := p.decoder.Token()
 	if err != nil {
-		p.logger.Fatal().Msgf("error while reading token : %v", err)
+		p.logError(p.logger.Fatal(), p.errorOffset(offset, err), err, "error while reading token")
 	}
 
 	return token
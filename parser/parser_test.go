@@ -0,0 +1,652 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshaykhairmode/j2csv/converter"
+	"github.com/rs/zerolog"
+)
+
+// convert runs the object stream in input through a parser configured by setup and returns the csv output.
+func convert(t *testing.T, input string, setup func(p *parser)) string {
+	t.Helper()
+
+	out := bytes.NewBuffer(nil)
+	p := NewParser(csv.NewWriter(out), json.NewDecoder(strings.NewReader(input)), &zerolog.Logger{})
+	if setup != nil {
+		setup(p)
+	}
+	p.ProcessObjects("")
+
+	return out.String()
+}
+
+func TestHeaderModes(t *testing.T) {
+
+	input := `{"a":1} {"b":2,"a":3} {"c":"x"}`
+
+	tests := []struct {
+		mode   string
+		sample int
+		want   string
+	}{
+		{HeadersFirst, 0, "a\n1\n3\n\n"},
+		{HeadersSample, 2, "a,b\n1,\n3,2\n,\n"},
+		{HeadersAll, 0, "a,b,c\n1,,\n3,2,\n,,x\n"},
+	}
+
+	for _, tt := range tests {
+		got := convert(t, input, func(p *parser) { p.SetHeaderMode(tt.mode, tt.sample) })
+		if got != tt.want {
+			t.Errorf("mode %s : expected %q, got %q", tt.mode, tt.want, got)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+
+	input := `{"id":1,"loc":{"city":"c1","geo":{"lat":1.5}},"tags":{}}`
+
+	tests := []struct {
+		sep   string
+		depth int
+		want  string
+	}{
+		{".", 0, "id,loc.city,loc.geo.lat,tags\n1,c1,1.5,{}\n"},
+		{"_", 1, "id,loc_city,loc_geo,tags\n1,c1,\"{\"\"lat\"\":1.5}\",{}\n"},
+	}
+
+	for _, tt := range tests {
+		got := convert(t, input, func(p *parser) { p.SetFlatten(true, tt.sep, tt.depth) })
+		if got != tt.want {
+			t.Errorf("sep %s depth %d : expected %q, got %q", tt.sep, tt.depth, tt.want, got)
+		}
+	}
+}
+
+func TestExplode(t *testing.T) {
+
+	input := `{"id":1,"orders":[{"no":"a","lines":[{"sku":"x"},{"sku":"y"}]},{"no":"b","lines":[]}]}`
+
+	got := convert(t, input, func(p *parser) { p.SetFlatten(true, ".", 0).SetExplode("orders.lines") })
+	want := "id,orders.no,orders.lines.sku\n1,a,x\n1,a,y\n1,b,\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	//without flatten the exploded fields are flattened, the other nested fields are written as JSON.
+	got = convert(t, `{"id":1,"meta":{"a":1},"items":[{"sku":"x","dims":{"w":2}},{"sku":"y"}]}`, func(p *parser) {
+		p.SetHeaderMode(HeadersAll, 0).SetExplode("items")
+	})
+	want = "id,meta,items.sku,items.dims.w\n1,\"{\"\"a\"\":1}\",x,2\n1,\"{\"\"a\"\":1}\",y,\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+
+	input := `{"id":1,"items":[{"sku":"x","parts":[{"p":1}]},{"sku":"y"}]} {"id":2}`
+
+	tables := map[string]*bytes.Buffer{}
+	newWriter := func(table string) (*csv.Writer, error) {
+		tables[table] = bytes.NewBuffer(nil)
+		return csv.NewWriter(tables[table]), nil
+	}
+
+	root := convert(t, input, func(p *parser) { p.SetHeaderMode(HeadersAll, 0).SetNormalize(true, newWriter) })
+
+	want := map[string]string{
+		"":            "_id,id\n1,1\n2,2\n",
+		"items":       "_id,_parent_id,_ordinal,sku\n1,1,1,x\n2,1,2,y\n",
+		"items.parts": "_id,_parent_id,_ordinal,p\n1,1,1,1\n",
+	}
+
+	if root != want[""] {
+		t.Errorf("root : expected %q, got %q", want[""], root)
+	}
+
+	for name, buf := range tables {
+		if buf.String() != want[name] {
+			t.Errorf("table %s : expected %q, got %q", name, want[name], buf.String())
+		}
+	}
+
+	if len(tables) != 2 {
+		t.Errorf("expected 2 child tables, got %d", len(tables))
+	}
+}
+
+func TestProcessLines(t *testing.T) {
+
+	input := "{\"a\":1}\n\n  {\"a\":2,\"b\":\"x\"}  \n{\"a\":3}"
+
+	out := bytes.NewBuffer(nil)
+	p := NewParser(csv.NewWriter(out), nil, &zerolog.Logger{}).SetHeaderMode(HeadersAll, 0)
+	p.ProcessLines(strings.NewReader(input), "")
+
+	want := "a,b\n1,\n2,x\n3,\n"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+
+	//a record which is not on a single line, found after the detection, is read like in the objects mode.
+	input += "\n// four\n{\n  \"a\": 4\n}\n{\"a\":5,\"b\":\"y\"}\n"
+
+	out.Reset()
+	p = NewParser(csv.NewWriter(out), nil, &zerolog.Logger{}).SetHeaderMode(HeadersAll, 0)
+	p.ProcessLines(strings.NewReader(input), "")
+
+	want = "a,b\n1,\n2,x\n3,\n4,\n5,y\n"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+
+	//the decoder is shared by the lines, the offsets of the syntax errors are still counted from the start of the line.
+	l := newLineSource(strings.NewReader("{\"a\":1}\n{\"a\":2}\n{\"a\":,}\n"))
+	var syntax *json.SyntaxError
+	for err := error(nil); err == nil; {
+		err = l.Decode(&map[string]any{})
+		if err != nil && (!errors.As(err, &syntax) || syntax.Offset != 6) {
+			t.Errorf("expected a syntax error at offset 6 of the line, got %v", err)
+		}
+	}
+}
+
+func TestRoot(t *testing.T) {
+
+	input := `{"meta":{"requestId":"r1","skip":[{"a":[1]}]},"data":{"items":[{"a":1},{"a":2}]},"after":true}`
+
+	for _, root := range []string{"/data/items", "$.data.items", "$['data'].items", "data.items"} {
+		out := bytes.NewBuffer(nil)
+		p := NewParser(csv.NewWriter(out), json.NewDecoder(strings.NewReader(input)), &zerolog.Logger{}).SetRoot(root, "meta.requestId")
+		p.ProcessArray("")
+
+		want := "a,meta.requestId\n1,r1\n2,r1\n"
+		if out.String() != want {
+			t.Errorf("root %s : expected %q, got %q", root, want, out.String())
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+
+	input := `{"id":1,"user":{"id":7,"pw_internal":"x"},"items":[{"sku":"a"},{"sku":"b"}],"meta_a":1,"meta_b":2}`
+
+	tests := []struct {
+		flatten               bool
+		sep, columns, exclude string
+		want                  string
+	}{
+		{false, ".", "meta_*,user.id,items[*].sku,items[1].sku,id", "", "meta_a,meta_b,user.id,items[*].sku,items[1].sku,id\n1,2,7,\"[\"\"a\"\",\"\"b\"\"]\",b,1\n"},
+		{true, ".", "", "*_internal,items,meta_a", "id,user.id,meta_b\n1,7,2\n"},
+		{true, ".", "user.*,id", "*_internal", "user.id,id\n7,1\n"},
+		{true, "_", "", "user.pw_internal,items", "id,user_id,meta_a,meta_b\n1,7,1,2\n"}, //excluded paths are joined with the separator.
+	}
+
+	for _, tt := range tests {
+		got := convert(t, input, func(p *parser) { p.SetFlatten(tt.flatten, tt.sep, 0).SetColumns(tt.columns, tt.exclude) })
+		if got != tt.want {
+			t.Errorf("columns %q exclude %q : expected %q, got %q", tt.columns, tt.exclude, tt.want, got)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+
+	input := `{"id":1,"zeta":{"b":1,"a":2},"alpha":"x","createdAt":5} {"beta":true,"id":2}`
+
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{OrderSource, "id,zeta.b,zeta.a,alpha,createdAt,beta\n1,1,2,x,5,\n2,,,,,true\n"},
+		{OrderSorted, "alpha,beta,createdAt,id,zeta.a,zeta.b\nx,,5,1,2,1\n,true,,2,,\n"},
+	}
+
+	for _, tt := range tests {
+		got := convert(t, input, func(p *parser) { p.SetHeaderMode(HeadersAll, 0).SetFlatten(true, ".", 0).SetOrder(tt.order) })
+		if got != tt.want {
+			t.Errorf("order %s : expected %q, got %q", tt.order, tt.want, got)
+		}
+	}
+}
+
+func TestOrderNormalize(t *testing.T) {
+
+	input := `{"id":1,"meta":{"items":[{"zeta":1,"loc":{"y":2,"b":3},"alpha":4}]}}`
+
+	for _, sep := range []string{".", "_"} {
+
+		items := bytes.NewBuffer(nil)
+		newWriter := func(table string) (*csv.Writer, error) {
+			return csv.NewWriter(items), nil
+		}
+
+		convert(t, input, func(p *parser) {
+			p.SetFlatten(true, sep, 0).SetNormalize(true, newWriter).SetOrder(OrderSource)
+		})
+
+		want := strings.ReplaceAll("_id,_parent_id,_ordinal,zeta,loc.y,loc.b,alpha\n1,1,1,1,2,3,4\n", ".", sep)
+		if items.String() != want {
+			t.Errorf("sep %s : expected %q, got %q", sep, want, items.String())
+		}
+	}
+}
+
+func TestDecodeRecord(t *testing.T) {
+
+	records := []string{
+		`{"a":1,"b":{"c":[1,{"d":"x"}],"e":null},"f":true,"g":false}`,
+		` { "s" : "tab\there \u00e9\ud83d\ude00 \"q\" \\" , "n":-1.5e-3, "big":12345678901234567890, "a":1, "a":2 } `,
+		"{\"bad\":\"\xff\",\"empty\":{},\"list\":[]}",
+	}
+
+	for _, record := range records {
+		want, got := map[string]any{}, map[string]any{}
+		if err := unmarshal([]byte(record), &want); err != nil {
+			t.Fatal(err)
+		}
+		p := NewParser(nil, nil, &zerolog.Logger{})
+		if err := p.decodeRecord([]byte(record), got, false); err != nil {
+			t.Fatalf("%s : %v", record, err)
+		}
+		if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", want) {
+			t.Errorf("%s : expected %#v, got %#v", record, want, got)
+		}
+	}
+
+	if err := NewParser(nil, nil, &zerolog.Logger{}).decodeRecord([]byte(`null`), map[string]any{}, false); err != nil {
+		t.Errorf("null : unexpected error %v", err) //an empty record, like unmarshal.
+	}
+
+	for _, record := range []string{`[1]`, `"x"`, `5`} {
+		if err := NewParser(nil, nil, &zerolog.Logger{}).decodeRecord([]byte(record), map[string]any{}, false); err == nil {
+			t.Errorf("%s : no error for a record which is not an object", record)
+		}
+	}
+
+	p := NewParser(nil, nil, &zerolog.Logger{}).SetFlatten(true, "_", 0)
+	p.decodeRecord([]byte(`{"z":1,"loc":{"y":[{"b":1,"a":2}]},"c":3}`), map[string]any{}, true)
+	if want := "map[c:5 loc:1 loc_y:2 loc_y_a:4 loc_y_b:3 z:0]"; fmt.Sprint(p.ranks) != want {
+		t.Errorf("expected ranks %s, got %v", want, p.ranks)
+	}
+}
+
+func TestNumbers(t *testing.T) {
+
+	got := convert(t, `{"id":9007199254740993,"v":0.1,"e":1e21}`, nil)
+	want := "id,v,e\n9007199254740993,0.1,1e21\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	tests := []struct {
+		text      string
+		decimals  int
+		sci       string
+		thousands string
+		want      string
+	}{
+		{"1234567.891", 2, SciKeep, ",", "1,234,567.89"},
+		{"-0.005", 2, SciKeep, "", "-0.01"},
+		{"0.004", 2, SciKeep, "", "0.00"},
+		{"9.995", 2, SciKeep, "", "10.00"},
+		{"12", 2, SciKeep, "", "12.00"},
+		{"1.5e-7", -1, SciOff, "", "0.00000015"},
+		{"1e21", -1, SciOff, ",", "1,000,000,000,000,000,000,000"},
+		{"1e21", -1, SciKeep, ",", "1e21"},
+		{"123456", -1, SciOn, "", "1.23456e+05"},
+		{"0.000123", 1, SciOn, "", "1.2e-04"},
+		{"9.99", 1, SciOn, "", "1.0e+01"},
+		{"9007199254740993", -1, SciKeep, " ", "9 007 199 254 740 993"},
+	}
+
+	for _, tt := range tests {
+		nf := numberFormat{decimals: tt.decimals, sci: tt.sci, thousands: tt.thousands}
+		if got := nf.formatNumber(tt.text); got != tt.want {
+			t.Errorf("%s decimals %d sci %q : expected %q, got %q", tt.text, tt.decimals, tt.sci, tt.want, got)
+		}
+	}
+}
+
+func TestEpoch(t *testing.T) {
+
+	tests := []struct {
+		text, unit string
+		want       time.Time
+	}{
+		{"1672325049", UnitSeconds, time.Unix(1672325049, 0)},
+		{"1672325049123", UnitMillis, time.Unix(1672325049, 123000000)},
+		{"1672325049123456", UnitMicros, time.Unix(1672325049, 123456000)},
+		{"1672325049123456789", UnitNanos, time.Unix(1672325049, 123456789)},
+		{"1672325049.5", UnitSeconds, time.Unix(1672325049, 500000000)},
+		{"1.672325049e9", UnitSeconds, time.Unix(1672325049, 0)},
+		{"1672325049", UnitAuto, time.Unix(1672325049, 0)},
+		{"1672325049123", UnitAuto, time.Unix(1672325049, 123000000)},
+		{"1672325049123456", UnitAuto, time.Unix(1672325049, 123456000)},
+		{"1672325049123456789", UnitAuto, time.Unix(1672325049, 123456789)},
+		{"-1.5", UnitSeconds, time.Unix(-2, 500000000)},
+	}
+
+	for _, tt := range tests {
+		got, ok := epoch(tt.text, tt.unit)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("%s %s : expected %v, got %v", tt.text, tt.unit, tt.want, got)
+		}
+	}
+
+	if _, ok := epoch("not a number", UnitSeconds); ok {
+		t.Errorf("expected text to fail")
+	}
+}
+
+func TestTimeFormat(t *testing.T) {
+
+	ts := time.Date(2023, 1, 1, 12, 30, 45, 123456000, time.UTC)
+
+	tests := []struct {
+		format, tz string
+		want       string
+	}{
+		{"", "", "2023-01-01T12:30:45Z"},
+		{FormatRFC3339, "Asia/Kolkata", "2023-01-01T18:00:45+05:30"},
+		{FormatISODate, "+12:00", "2023-01-02"},
+		{"%d/%m/%Y %H:%M:%S.%L", "UTC", "01/01/2023 12:30:45.123"},
+		{"02 Jan 06 15:04", "+0100", "01 Jan 23 13:30"},
+		{FormatExcelSerial, "UTC", "44927.521355595556"},
+	}
+
+	for _, tt := range tests {
+		tf, err := newTimeFormat(tt.format, tt.tz)
+		if err != nil {
+			t.Fatalf("%q %q : %v", tt.format, tt.tz, err)
+		}
+		if got := tf.format(ts); got != tt.want {
+			t.Errorf("%q %q : expected %q, got %q", tt.format, tt.tz, tt.want, got)
+		}
+	}
+
+	for _, format := range []string{"%Q", "%Y%"} {
+		if _, err := newTimeFormat(format, ""); err == nil {
+			t.Errorf("expected %q to fail", format)
+		}
+	}
+
+	out := bytes.NewBuffer(nil)
+	p := NewParser(csv.NewWriter(out), json.NewDecoder(strings.NewReader(`{"n":1672576245,"s":"1672576245000"}`)), &zerolog.Logger{})
+	p.SetTimeFormat(FormatISODateTime, "Asia/Kolkata").ProcessObjects("n,s:ms")
+
+	want := "n,s\n2023-01-01T18:00:45,2023-01-01T18:00:45\n" //numbers and numeric strings are written the same way.
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestInputLayouts(t *testing.T) {
+
+	input := `{"ts":"2023-01-02T10:00:00.123+05:30"} {"ts":"2023-01-02 10:00:00"} {"ts":"02/01/2023"} {"ts":"1672653600"} {"ts":"soon"}`
+
+	out := bytes.NewBuffer(nil)
+	p := NewParser(csv.NewWriter(out), json.NewDecoder(strings.NewReader(input)), &zerolog.Logger{})
+	p.SetTimeFormat(FormatRFC3339Nano, "UTC").SetInputLayouts([]string{LayoutAuto, "%d/%m/%Y"}).ProcessObjects("ts")
+
+	want := "ts\n2023-01-02T04:30:00.123Z\n2023-01-02T10:00:00Z\n2023-01-02T00:00:00Z\n2023-01-02T10:00:00Z\nsoon\n"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestAutoTime(t *testing.T) {
+
+	input := `{"id":1700000000,"created":1672653600123,"seen":"2023-01-02 10:00:00","name":"x","count":12,"born":"01/02/2023","paid_at":1672653600,"ts":1672653600}
+	{"id":1700000001,"created":1672653600,"seen":null,"name":"y","count":13,"born":"02/02/2023","paid_at":1672653600,"ts":1672653600}`
+
+	got := convert(t, input, func(p *parser) {
+		p.SetHeaderMode(HeadersSample, 10).SetTimeFormat(FormatISODate, "UTC").SetAutoTime(true, "born", "ts").SetInputLayouts([]string{"%m/%d/%Y"})
+	})
+
+	//the id numbers are in the range of the timestamps, but only columns with a time like name are converted.
+	want := "id,created,seen,name,count,born,paid_at,ts\n1700000000,2023-01-02,2023-01-02 10:00:00,x,12,2023-01-02,2023-01-02,1672653600\n" +
+		"1700000001,2023-01-02,,y,13,2023-02-02,2023-01-02,1672653600\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	//the values are sampled from more records than the headers, the third updated is not a timestamp.
+	got = convert(t, `{"created":1672653600,"updated":1672653600} {"created":1672653600,"updated":1672653600,"x":1} {"created":1672653600,"updated":7}`, func(p *parser) {
+		p.SetTimeFormat(FormatISODate, "UTC").SetAutoTime(true, "", "")
+	})
+
+	want = "created,updated\n2023-01-02,1672653600\n2023-01-02,1672653600\n2023-01-02,7\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	//the decision for every column is logged at info.
+	logs := bytes.NewBuffer(nil)
+	logger := zerolog.New(logs).Level(zerolog.InfoLevel)
+	NewParser(csv.NewWriter(io.Discard), json.NewDecoder(strings.NewReader(input)), &logger).SetAutoTime(true, "born", "ts").ProcessObjects("created:ms")
+
+	for _, column := range []string{"id", "created", "seen", "name", "count", "born", "paid_at", "ts"} {
+		if !strings.Contains(logs.String(), `"column":"`+column+`"`) {
+			t.Errorf("no auto time decision logged for %s", column)
+		}
+	}
+}
+
+func TestTimeName(t *testing.T) {
+
+	names := map[string]bool{
+		"created": true, "createdAt": true, "updated_at": true, "event_time": true, "ts": true, "timestamp": true, "lastLoginTs": true,
+		"expiresOn": true, "user.deleted_at": true, "created_at_ms": true, "HTTPTime": true, "EPOCH": true,
+		"runtime": false, "createdBy": false, "updatedCount": false, "candidate": false, "status": false, "time_zone": false, "id": false,
+	}
+
+	for name, want := range names {
+		if got := isTimeName(name); got != want {
+			t.Errorf("%s : expected %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestWhere(t *testing.T) {
+
+	input := `{"name":"a","age":20,"address":{"zip":"1"}} {"name":"b","age":17,"extra":1,"address":{"zip":"2"}} {"name":"c","age":30,"address":{}}
+	{"name":"d","age":40,"address":{"zip":"4"}}`
+
+	for _, mode := range []string{HeadersFirst, HeadersAll} {
+		got := convert(t, input, func(p *parser) {
+			p.SetHeaderMode(mode, 0).SetWhere(`age >= 18 && has(address.zip)`)
+		})
+		want := "name,age,address\na,20,\"{\"\"zip\"\":\"\"1\"\"}\"\nd,40,\"{\"\"zip\"\":\"\"4\"\"}\"\n"
+		if got != want {
+			t.Errorf("mode %s : expected %q, got %q", mode, want, got)
+		}
+	}
+}
+
+func TestDerive(t *testing.T) {
+
+	input := `{"fname":"Ada","lname":"L","qty":3,"price":0.1,"createdAt":1672653600} {"fname":"Bob","qty":2,"createdAt":"2024-03-01"}`
+
+	got := convert(t, input, func(p *parser) {
+		p.SetDefault("NA").SetDerive([]string{`full_name=fname + " " + lname`, "total = qty * price", "born=year(createdAt)", "late=born > 2023"}).SetWhere("total != null || late")
+	})
+
+	want := "fname,lname,qty,price,createdAt,full_name,total,born,late\nAda,L,3,0.1,1672653600,Ada L,0.3,2023,false\nBob,NA,2,NA,2024-03-01,Bob ,NA,2024,true\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestTransform(t *testing.T) {
+
+	input := `{"name":"  ada lovelace ","sex":"F","cents":12345,"active":true,"code":"7","phone":"+44-20-1234"}
+	{"name":"bob","sex":"X","cents":5,"active":false,"code":"1234567"} {"name":"cy"}`
+
+	got := convert(t, input, func(p *parser) {
+		p.SetDefault("-").SetTransform([]string{
+			`name=trim|upper|substr(0, 3)`,
+			`sex=map("M", "Male", "F", "Female", "Unknown")`,
+			`cents=scale(0.01)|round(2)`,
+			`active=bool("Y", "N")`,
+			`code=pad(5, "0")|substr(-5)`,
+			`ph*=replace("[^0-9]", "")|default("none")`,
+		})
+	})
+
+	want := "name,sex,cents,active,code,phone\nADA,Female,123.45,Y,00007,44201234\nBOB,Unknown,0.05,N,34567,none\n" +
+		"CY,-,-,-,-,none\n" //missing values are the placeholder or the text of the default step.
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestMask(t *testing.T) {
+
+	input := `{"id":"u1","email":"a@b.co","phone":"9876543210","card":"12","note":"call 9876543210 or 1234567890"} {"id":"u2"}`
+
+	got := convert(t, input, func(p *parser) {
+		p.SetDefault("NULL").SetMask([]string{"email=sha256", "phone=partial(4)", "card=partial", "i?=hmac"}, []string{`[0-9]{10}=mask`}, "secret")
+	})
+
+	want := "id,email,phone,card,note\n" +
+		"8a629605d37d74d6c872140bed892da5f3739914899fdd6171dfe43031786151," +
+		"80305c9bb1bb2480e03894350e0a8a366dcbdeb302e69e0817aa0743abd77054," +
+		"******3210,**,call **** or ****\n" +
+		"551aae2f72d039d1f436392df204ed8ce365ecce667ff15694282afff8716233,NULL,NULL,NULL,NULL\n" //missing values are not masked.
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestOnErrorSkip(t *testing.T) {
+
+	tests := []struct {
+		name, input string
+		process     func(p *parser, input io.Reader)
+		want        string
+		rejected    []rejected //the error messages depend on the Go version, only the offset and the raw record are checked.
+	}{
+		{
+			"objects",
+			"{\"a\":1}\n{\"a\":2,,\"b\":{\"c\":\"}\"}}\n{\"a\":\"3\n{\"a\":4}\n[5]\n{\"a\":6}",
+			func(p *parser, input io.Reader) { p.ProcessObjects("") },
+			"a\n1\n4\n6\n",
+			[]rejected{{Offset: 8, Raw: `{"a":2,,"b":{"c":"}"}}`}, {Offset: 31, Raw: `{"a":"3`}, {Offset: 47, Raw: `[5]`}},
+		},
+		{
+			"array",
+			`[{"a":1}, {"a":2 "b":3}, {"a":3}, oops, {"a":4},]`,
+			func(p *parser, input io.Reader) { p.ProcessArray("") },
+			"a\n1\n3\n4\n",
+			[]rejected{{Offset: 10, Raw: `{"a":2 "b":3}`}, {Offset: 34, Raw: "oops"}, {Offset: 48}},
+		},
+		{
+			"lines",
+			"{\"a\":1}\n[4]\n{\"a\":3} {\"a\":9}\n{\"a\":5}\n{\"a\":2\n{\"a\":6}", //the rest is read as objects from the cut record.
+			func(p *parser, input io.Reader) { p.ProcessLines(input, "") },
+			"a\n1\n3\n5\n6\n",
+			[]rejected{{Offset: 8, Raw: "[4]"}, {Offset: 36, Raw: `{"a":2`}},
+		},
+	}
+
+	for _, tt := range tests {
+		out, dead := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+		input := strings.NewReader(tt.input)
+		p := NewParser(csv.NewWriter(out), json.NewDecoder(input), &zerolog.Logger{}).SetOnError(OnErrorSkip, 0, dead, input)
+		tt.process(p, input)
+
+		if out.String() != tt.want {
+			t.Errorf("%s : expected %q, got %q", tt.name, tt.want, out.String())
+		}
+
+		got := []rejected{}
+		decoder := json.NewDecoder(dead)
+		for decoder.More() {
+			var r rejected
+			if err := decoder.Decode(&r); err != nil {
+				t.Fatal(err)
+			}
+			if r.Error == "" {
+				t.Errorf("%s : no error for the record at %d", tt.name, r.Offset)
+			}
+			got = append(got, rejected{Offset: r.Offset, Raw: r.Raw})
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(tt.rejected) || p.Rejected() != len(tt.rejected) {
+			t.Errorf("%s : expected rejected %v, got %v", tt.name, tt.rejected, got)
+		}
+	}
+
+	//with masks the raw text of the rejected records is left out.
+	dead := bytes.NewBuffer(nil)
+	input := strings.NewReader("{\"email\":\"a@b.c\"}\n{\"email\":\"x@y.z\",,}\n")
+	p := NewParser(csv.NewWriter(io.Discard), json.NewDecoder(input), &zerolog.Logger{}).SetOnError(OnErrorSkip, 0, dead, input).SetMask([]string{"email=mask"}, nil, "")
+	p.ProcessObjects("")
+
+	if strings.Contains(dead.String(), "x@y.z") || !strings.Contains(dead.String(), `"masked":true`) {
+		t.Errorf("expected the raw text to be left out, got %s", dead.String())
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+
+	input := "// orders\n{\"id\":1,\"tags\":[\"a\"]}\n/* two */ {\"id\":2}\n{\"id\":3,\"tags\":[]} // three\n{\"id\":4}\n{\"id\":5,\"tags\":[\"b\",\"c\"]}\n"
+	cut := strings.Index(input, "{\"id\":4}") //the first run stops before the fourth record.
+	path := filepath.Join(t.TempDir(), "ckpt.json")
+
+	run := func(out *bytes.Buffer, input string, cp *Checkpoint) {
+		r, tracker := converter.NewTracked(strings.NewReader(input), 0, converter.JSON, &zerolog.Logger{})
+		p := NewParser(csv.NewWriter(out), json.NewDecoder(r), &zerolog.Logger{}).SetFlatten(true, ".", 0).SetLocator("orders.json", tracker)
+		if cp != nil {
+			tracker.Resume(cp.Offset, cp.Line, cp.Column)
+			p.Resume(cp)
+		}
+		p.SetCheckpoint(path, time.Nanosecond, Checkpoint{}, func() (map[string]int64, error) { return map[string]int64{"out": int64(out.Len())}, nil })
+		p.ProcessObjects("")
+	}
+
+	want := bytes.NewBuffer(nil)
+	run(want, input, nil)
+
+	out := bytes.NewBuffer(nil)
+	run(out, input[:cut], nil)
+
+	cp, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.Records != 3 || cp.Line != 4 || input[cp.Offset:cp.Offset+8] != " // thre" {
+		t.Fatalf("unexpected checkpoint %+v", cp)
+	}
+
+	out.Truncate(int(cp.Outputs["out"]))
+	run(out, input[cp.Offset:], cp)
+
+	if out.String() != want.String() {
+		t.Errorf("expected %q, got %q", want.String(), out.String())
+	}
+}
+
+func TestAppend(t *testing.T) {
+
+	got := convert(t, `{"a":1,"b":2} {"a":3,"b":4}`, func(p *parser) {
+		p.SetAppend(func(table string) ([]string, error) { return []string{"a", "b"}, nil })
+	})
+
+	if want := "1,2\n3,4\n"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,205 @@
+package parser
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"unicode/utf8"
+)
+
+// recordDecoder decodes a raw record which was read by the source. The record is valid JSON already, so it is decoded in a single walk
+// which builds the values like unmarshal and ranks the key paths in the same pass, instead of decoding the raw record again for each.
+type recordDecoder struct {
+	p    *parser
+	data []byte
+	pos  int
+	rank bool //rank the key paths not seen before, for the source order.
+}
+
+// decodeRecord decodes the raw record into object, numbers are json.Number. If rank is set the key paths are ranked as well.
+// A record which is not an object is an error, except null which leaves object empty like unmarshal.
+func (p *parser) decodeRecord(raw []byte, object map[string]any, rank bool) error {
+
+	d := recordDecoder{p: p, data: raw, rank: rank}
+
+	d.skipSpace()
+	if d.pos >= len(d.data) {
+		return io.ErrUnexpectedEOF
+	}
+
+	switch d.data[d.pos] {
+	case '{':
+		return d.object("", object)
+	case 'n':
+		return nil
+	}
+
+	value, err := d.value("")
+	if err != nil {
+		return err
+	}
+
+	return &json.UnmarshalTypeError{Value: typeName(value), Type: reflect.TypeOf(object), Offset: int64(d.pos)}
+}
+
+func (d *recordDecoder) value(prefix string) (any, error) {
+
+	d.skipSpace()
+	if d.pos >= len(d.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	switch d.data[d.pos] {
+	case '{':
+		object := map[string]any{}
+		return object, d.object(prefix, object)
+	case '[':
+		return d.array(prefix)
+	case '"':
+		return d.string()
+	}
+
+	start := d.pos
+	for d.pos < len(d.data) && !isDelim(d.data[d.pos]) {
+		d.pos++
+	}
+
+	switch literal := string(d.data[start:d.pos]); literal {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	default:
+		return json.Number(literal), nil
+	}
+}
+
+func (d *recordDecoder) object(prefix string, object map[string]any) error {
+
+	d.pos++ //{
+
+	for {
+		d.skipSpace()
+		if d.pos >= len(d.data) {
+			return io.ErrUnexpectedEOF
+		}
+
+		switch d.data[d.pos] {
+		case '}':
+			d.pos++
+			return nil
+		case ',':
+			d.pos++
+			continue
+		}
+
+		key, err := d.string()
+		if err != nil {
+			return err
+		}
+
+		path := key
+		if d.rank {
+			if prefix != "" {
+				path = prefix + d.p.sep + key
+			}
+			if _, ok := d.p.ranks[path]; !ok {
+				d.p.ranks[path] = len(d.p.ranks)
+			}
+		}
+
+		d.skipSpace()
+		d.pos++ //:
+
+		value, err := d.value(path)
+		if err != nil {
+			return err
+		}
+		object[key] = value
+	}
+}
+
+// array decodes the elements of the array. The elements share the path of the array, like the columns of exploded and normalized arrays.
+func (d *recordDecoder) array(prefix string) ([]any, error) {
+
+	d.pos++ //[
+	elements := []any{}
+
+	for {
+		d.skipSpace()
+		if d.pos >= len(d.data) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		switch d.data[d.pos] {
+		case ']':
+			d.pos++
+			return elements, nil
+		case ',':
+			d.pos++
+			continue
+		}
+
+		value, err := d.value(prefix)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, value)
+	}
+}
+
+// string decodes the string at the position. Strings without escapes are used as they are, the others are unquoted by encoding/json.
+func (d *recordDecoder) string() (string, error) {
+
+	start := d.pos
+	escaped := false
+
+	for d.pos++; d.pos < len(d.data); d.pos++ {
+		switch d.data[d.pos] {
+		case '\\':
+			escaped = true
+			d.pos++
+		case '"':
+			d.pos++
+			text := d.data[start+1 : d.pos-1]
+			if !escaped && utf8.Valid(text) {
+				return string(text), nil
+			}
+			var s string
+			err := json.Unmarshal(d.data[start:d.pos], &s)
+			return s, err
+		}
+	}
+
+	return "", io.ErrUnexpectedEOF
+}
+
+func (d *recordDecoder) skipSpace() {
+	for d.pos < len(d.data) && isSpace(d.data[d.pos]) {
+		d.pos++
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDelim(c byte) bool {
+	return isSpace(c) || c == ',' || c == ']' || c == '}'
+}
+
+// typeName is the JSON type of the value, like in the errors of encoding/json.
+func typeName(value any) string {
+	switch value.(type) {
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	default:
+		return "number"
+	}
+}
@@ -0,0 +1,317 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Error policies.
+const (
+	OnErrorFail = "fail" //a record which can not be decoded stops the conversion.
+	OnErrorSkip = "skip" //a record which can not be decoded is written to the dead letter file and the conversion continues.
+)
+
+// maxRejectedBytes is the number of bytes of a rejected record which are written to the dead letter file.
+const maxRejectedBytes = 1 << 20
+
+// rejects are the records which could not be decoded in skip mode.
+type rejects struct {
+	mode  string
+	max   int           //number of rejected records which stops the conversion, 0 means no limit.
+	count int           //number of rejected records.
+	out   *bufio.Writer //dead letter file, one JSON object per rejected record.
+	input *countReader  //reader of the decoder, read again after a syntax error.
+}
+
+// countReader counts the bytes read by the decoder, the offset of the bytes buffered by the decoder is known from it.
+type countReader struct {
+	r    io.Reader
+	read int64
+}
+
+func (c *countReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.read += int64(n)
+	return n, err
+}
+
+// rejected is a line of the dead letter file.
+type rejected struct {
+	Offset int64  `json:"offset"`           //offset of the record in the input.
+	Line   int    `json:"line,omitempty"`   //line of the record, if known.
+	Column int    `json:"column,omitempty"` //column of the record, if known.
+	Error  string `json:"error"`
+	Raw    string `json:"raw"`
+	Masked bool   `json:"masked,omitempty"` //the raw text is left out, the masks can not be applied to a record which could not be decoded.
+}
+
+// offsetSource is the decoder created after a syntax error. Its offsets are counted from the start of the input.
+type offsetSource struct {
+	*json.Decoder
+	base int64
+}
+
+func (o offsetSource) InputOffset() int64 {
+	return o.base + o.Decoder.InputOffset()
+}
+
+// SetOnError sets what happens with records which can not be decoded. In skip mode they are written to deadLetter with their input offset and error,
+// till more than maxErrors records are rejected. input is the reader of the decoder, it should not be read yet. The decoder is created again on it
+// so that it can continue after a syntax error.
+func (p *parser) SetOnError(mode string, maxErrors int, deadLetter io.Writer, input io.Reader) *parser {
+
+	switch mode {
+	case "", OnErrorFail:
+		mode = OnErrorFail
+	case OnErrorSkip:
+		if deadLetter == nil {
+			deadLetter = io.Discard
+		}
+	default:
+		p.logger.Fatal().Msgf("unknown error policy %q, allowed values are %s and %s", mode, OnErrorFail, OnErrorSkip)
+	}
+
+	p.rejects = rejects{mode: mode, max: maxErrors}
+	if deadLetter != nil {
+		p.rejects.out = bufio.NewWriter(deadLetter)
+	}
+
+	if mode == OnErrorSkip && input != nil && p.decoder != nil {
+		p.rejects.input = &countReader{r: input}
+		p.decoder = json.NewDecoder(p.rejects.input)
+		p.decoder.UseNumber()
+		p.src = p.decoder
+	}
+
+	return p
+}
+
+// Rejected returns the number of records which were rejected in skip mode.
+func (p *parser) Rejected() int {
+	return p.rejects.count
+}
+
+// nextRecord decodes the next record into object. The raw record is returned if keepRaw is set or in skip mode. If rank is set the key
+// paths are ranked while the record is decoded, in source order. A record which can not be decoded is fatal, in skip mode it is rejected
+// and false is returned.
+func (p *parser) nextRecord(object map[string]any, keepRaw, rank bool) (json.RawMessage, bool) {
+
+	rank = rank && p.order == OrderSource
+
+	if !keepRaw && !rank && p.rejects.mode != OnErrorSkip {
+		offset := p.src.InputOffset()
+		if err := p.src.Decode(&object); err != nil {
+			if p.toObjects(err) {
+				if !p.src.More() {
+					return nil, false
+				}
+				for key := range object {
+					delete(object, key)
+				}
+				return p.nextRecord(object, keepRaw, rank)
+			}
+			p.logError(p.logger.Fatal(), p.errorOffset(offset, err), err, "error while decoding object")
+		}
+		return nil, true
+	}
+
+	offset := p.src.InputOffset()
+
+	var raw json.RawMessage
+	if err := p.src.Decode(&raw); err != nil {
+		if p.toObjects(err) {
+			if !p.src.More() {
+				return nil, false
+			}
+			return p.nextRecord(object, keepRaw, rank)
+		}
+		p.reject(offset, err)
+		return nil, false
+	}
+
+	if err := p.decodeRecord(raw, object, rank); err != nil { //valid JSON which is not an object.
+		p.rejectRaw(offset, offset, raw, err)
+		return nil, false
+	}
+
+	return raw, true
+}
+
+// reject rejects the record which could not be read and moves the source to the next record.
+func (p *parser) reject(offset int64, err error) {
+
+	at := p.errorOffset(offset, err) //the source is changed by resync.
+
+	var syntax *json.SyntaxError
+	if p.rejects.mode != OnErrorSkip || !(errors.As(err, &syntax) || errors.Is(err, io.ErrUnexpectedEOF)) { //read errors can not be skipped.
+		p.logError(p.logger.Fatal(), at, err, "error while decoding object")
+	}
+
+	if l, ok := p.src.(*lineSource); ok { //the line is consumed already.
+		p.rejectRaw(offset, at, l.last, err)
+		return
+	}
+
+	raw, offset := p.resync()
+	p.rejectRaw(offset, at, raw, err)
+}
+
+// rejectRaw writes the record at offset to the dead letter file, at is the offset of the error.
+func (p *parser) rejectRaw(offset, at int64, raw []byte, err error) {
+
+	if p.rejects.mode != OnErrorSkip {
+		p.logError(p.logger.Fatal(), at, err, "error while decoding object")
+	}
+
+	p.rejects.count++
+
+	p.logError(p.logger.Warn(), at, err, "rejected record")
+
+	if len(raw) > maxRejectedBytes {
+		raw = raw[:maxRejectedBytes]
+	}
+
+	masked := len(p.masks) > 0
+	if masked { //the masked values would be written to the dead letter file as they are.
+		raw = nil
+	}
+
+	loc := p.locate(offset)
+	line, _ := json.Marshal(rejected{Offset: loc.Offset, Line: loc.Line, Column: loc.Column, Error: err.Error(), Raw: string(raw), Masked: masked})
+	p.rejects.out.Write(line)
+	if err := p.rejects.out.WriteByte('\n'); err != nil {
+		p.logger.Fatal().Err(err).Msg("error while writing to dead letter file")
+	}
+
+	if p.rejects.max > 0 && p.rejects.count > p.rejects.max {
+		p.rejects.out.Flush()
+		p.logger.Fatal().Msgf("more than %d records were rejected, stopping", p.rejects.max)
+	}
+}
+
+// resync skips the record at offset which has a syntax error and creates the decoder again after it. The end of the record is found by matching
+// the braces outside of strings. JSON strings can not have newlines, so a newline ends a string which was not closed. In a stream of objects
+// a { at the start of a line starts the next record, so a record which was cut off does not swallow the next ones.
+// The skipped bytes and their offset are returned.
+func (p *parser) resync() ([]byte, int64) {
+
+	if p.rejects.input == nil {
+		p.logger.Fatal().Int64("offset", p.src.InputOffset()).Msg("can not skip the record, the input of the decoder is not set")
+	}
+
+	buffered, _ := io.ReadAll(p.decoder.Buffered())
+	offset := p.rejects.input.read - int64(len(buffered)) //the buffered bytes start after the last decoded record.
+
+	br := bufio.NewReaderSize(io.MultiReader(bytes.NewReader(buffered), p.rejects.input), 1<<16)
+
+	raw := []byte{}
+	read := int64(0)
+
+	next := func() (byte, bool) {
+		c, err := br.ReadByte()
+		if err != nil {
+			return 0, false
+		}
+		read++
+		if len(raw) < maxRejectedBytes {
+			raw = append(raw, c)
+		}
+		return c, true
+	}
+
+	peek := func() (byte, bool) {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, false
+		}
+		return b[0], true
+	}
+
+	skipSpace := func() {
+		for c, ok := peek(); ok && strings.IndexByte(" \t\r\n", c) >= 0; c, ok = peek() {
+			br.ReadByte()
+			read++
+		}
+	}
+
+	skipSpace()
+	if c, ok := peek(); ok && c == ',' && p.array { //the comma after the last decoded element.
+		br.ReadByte()
+		read++
+		skipSpace()
+	}
+	start := offset + read
+
+	depth, inString, escaped, lineStart := 0, false, false, false
+	for {
+		c, ok := peek()
+		if !ok {
+			break
+		}
+
+		if depth == 0 && len(raw) > 0 { //records which are not objects or arrays end at a separator.
+			if strings.IndexByte(" \t\r\n,]{[", c) >= 0 {
+				break
+			}
+		}
+
+		if lineStart && c == '{' && !p.array && len(raw) > 0 {
+			break
+		}
+
+		if c == ']' && p.array && len(raw) <= 0 { //the end of the array after a trailing comma, not a record.
+			break
+		}
+
+		next()
+		lineStart = false
+
+		switch {
+		case c == '\n':
+			inString, escaped, lineStart = false, false, true
+		case inString && escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		}
+
+		if depth <= 0 && (c == '}' || c == ']') {
+			break
+		}
+	}
+
+	base := offset + read
+
+	var rest io.Reader = br
+	if p.array { //the rest of the array is read as a new array, after the comma which followed the record.
+		skipSpace()
+		if c, ok := peek(); ok && c == ',' {
+			br.ReadByte()
+			read++
+		}
+		base = offset + read - 1
+		rest = io.MultiReader(strings.NewReader("["), br)
+	}
+
+	p.rejects.input = &countReader{r: rest, read: base} //counts from the start of the input, like the first decoder.
+	p.decoder = json.NewDecoder(p.rejects.input)
+	p.decoder.UseNumber()
+	p.src = offsetSource{Decoder: p.decoder, base: base}
+
+	if p.array {
+		p.token()
+	}
+
+	return bytes.TrimSpace(raw), start
+}
@@ -0,0 +1,240 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rootField is a field of the document outside the records which is copied to every row.
+type rootField struct {
+	path  []string
+	name  string //column name of the field.
+	value any
+	found bool
+}
+
+// SetRoot sets the path of the records array inside the document, for example /data/items, $.data.items or data.items.
+// fields is a comma separated list of paths of other fields of the document which are copied to every row, like meta.requestId.
+// Only the fields which come before the records array in the document can be copied, as the document is streamed.
+func (p *parser) SetRoot(root, fields string) *parser {
+
+	root = strings.TrimSpace(root)
+	if root == "" {
+		if strings.TrimSpace(fields) != "" {
+			p.logger.Fatal().Msg("root fields need the root path to be set")
+		}
+		return p
+	}
+
+	path, err := parsePath(root)
+	if err != nil {
+		p.logger.Fatal().Err(err).Msgf("invalid root path : %s", root)
+	}
+	p.root = path
+
+	for _, field := range strings.Split(fields, ",") {
+
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		path, err := parsePath(field)
+		if err != nil || len(path) <= 0 {
+			p.logger.Fatal().Err(err).Msgf("invalid root field : %s", field)
+		}
+
+		p.rootFields = append(p.rootFields, &rootField{path: path, name: strings.Join(path, p.sep)})
+	}
+
+	return p
+}
+
+// parsePath parses a JSON pointer (/a/b/0), a JSONPath ($.a.b[0] or $['a']) or a dotted path (a.b.0) into its keys.
+// [*] is parsed as the key *.
+func parsePath(expr string) ([]string, error) {
+
+	if strings.HasPrefix(expr, "/") {
+		keys := strings.Split(expr[1:], "/")
+		for i, key := range keys {
+			keys[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+		}
+		return keys, nil
+	}
+
+	expr = strings.TrimPrefix(expr, "$")
+
+	keys := []string{}
+	for i := 0; i < len(expr); {
+		switch expr[i] {
+		case '.':
+			i++
+		case '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ] at %d", i)
+			}
+			key := expr[i+1 : i+end]
+			if unquoted, ok := unquote(key); ok {
+				key = unquoted
+			} else if _, err := strconv.Atoi(key); err != nil && key != "*" {
+				return nil, fmt.Errorf("invalid index %q at %d", key, i)
+			}
+			keys = append(keys, key)
+			i += end + 1
+		default:
+			end := strings.IndexAny(expr[i:], ".[")
+			if end < 0 {
+				end = len(expr) - i
+			}
+			keys = append(keys, expr[i:i+end])
+			i += end
+		}
+	}
+
+	return keys, nil
+}
+
+// unquote removes the single or double quotes around s.
+func unquote(s string) (string, bool) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], true
+	}
+	return s, false
+}
+
+// seekRoot reads tokens till the start of the records array at the root path.
+// The root fields found on the way are kept to be copied to every row.
+func (p *parser) seekRoot(path, at []string) {
+
+	token := p.token()
+
+	if len(path) <= 0 {
+		if token != json.Delim('[') {
+			p.logger.Fatal().Msgf("root %s is not an array, got : %v", strings.Join(p.root, "."), token)
+		}
+		p.logger.Debug().Strs("root", p.root).Msg("found root array")
+		return
+	}
+
+	switch token {
+	case json.Delim('{'):
+		for p.decoder.More() {
+			key, _ := p.token().(string)
+			keyPath := append(append([]string(nil), at...), key)
+			if key == path[0] {
+				p.seekRoot(path[1:], keyPath)
+				return
+			}
+			p.skipValue(keyPath)
+		}
+	case json.Delim('['):
+		index, err := strconv.Atoi(path[0])
+		if err != nil {
+			p.logger.Fatal().Msgf("root path %s has key %q where the document has an array", strings.Join(p.root, "."), path[0])
+		}
+		for i := 0; p.decoder.More(); i++ {
+			keyPath := append(append([]string(nil), at...), strconv.Itoa(i))
+			if i == index {
+				p.seekRoot(path[1:], keyPath)
+				return
+			}
+			p.skipValue(keyPath)
+		}
+	}
+
+	p.logger.Fatal().Msgf("root %s not found in the document", strings.Join(p.root, "."))
+}
+
+// skipValue skips the value at path. If a root field is inside the value, the value is decoded and the field is kept.
+func (p *parser) skipValue(path []string) {
+
+	for _, field := range p.rootFields {
+		if hasPrefix(field.path, path) {
+			var value any
+			if err := p.decoder.Decode(&value); err != nil {
+				p.logger.Fatal().Int64("offset", p.decoder.InputOffset()).Msgf("error while decoding root field : %v", err)
+			}
+			p.setRootFields(path, value)
+			return
+		}
+	}
+
+	depth := 0 //Skip the tokens without decoding, the value could be big.
+	for {
+		switch p.token() {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth <= 0 {
+			return
+		}
+	}
+}
+
+// setRootFields keeps the root fields which are inside the value at path.
+func (p *parser) setRootFields(path []string, value any) {
+
+	for _, field := range p.rootFields {
+
+		if !hasPrefix(field.path, path) {
+			continue
+		}
+
+		v := value
+		for _, key := range field.path[len(path):] {
+			switch c := v.(type) {
+			case map[string]any:
+				v = c[key]
+			case []any:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(c) {
+					v = nil
+					break
+				}
+				v = c[i]
+			default:
+				v = nil
+			}
+		}
+
+		field.value = v
+		field.found = true
+	}
+}
+
+// addRootFields copies the root fields to the object.
+func (p *parser) addRootFields(object map[string]any) {
+	for _, field := range p.rootFields {
+		object[field.name] = field.value
+	}
+}
+
+// logMissingRootFields warns about the root fields which were not found before the records array.
+func (p *parser) logMissingRootFields() {
+	for _, field := range p.rootFields {
+		if !field.found {
+			p.logger.Warn().Str("field", field.name).Msg("root field not found before the records, it will be empty")
+		}
+	}
+}
+
+// hasPrefix reports if prefix is a prefix of path.
+func hasPrefix(path, prefix []string) bool {
+
+	if len(prefix) > len(path) {
+		return false
+	}
+
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
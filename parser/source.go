@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/akshaykhairmode/j2csv/converter"
+)
+
+// source gives the records of the input one by one. *json.Decoder is a source, lineSource reads newline delimited JSON.
+type source interface {
+	More() bool
+	Decode(v any) error
+	InputOffset() int64
+	Buffered() io.Reader
+}
+
+// lineSource reads one record per line. Blank lines are skipped.
+type lineSource struct {
+	r      *bufio.Reader
+	feed   lineFeed      //lines given to the decoder one by one.
+	dec    *json.Decoder //decoder of the lines, created again after a line which is not a single value.
+	fed    int64         //offset of the decoder at the start of the line being decoded.
+	line   []byte        //next record, read by More.
+	rest   []byte        //line of the next record from its start, read as a stream of objects if the record is not on a single line.
+	last   []byte        //last decoded record, kept for the dead letter file.
+	next   int64         //offset of the next record.
+	start  int64         //offset of the last decoded record.
+	read   int64         //bytes read from r.
+	offset int64         //offset after the last decoded record.
+	err    error         //read error other than EOF, returned by the next Decode.
+}
+
+func newLineSource(r io.Reader) *lineSource {
+	return &lineSource{r: bufio.NewReaderSize(r, 1<<16)}
+}
+
+func (l *lineSource) More() bool {
+
+	if l.line != nil || l.err != nil {
+		return true
+	}
+
+	for {
+		line, err := l.r.ReadBytes('\n')
+		l.read += int64(len(line))
+
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			l.line = trimmed
+			l.rest = bytes.TrimLeft(line, " \t\r\n")
+			l.next = l.read - int64(len(l.rest))
+			return true
+		}
+
+		if err == io.EOF {
+			return false
+		}
+
+		if err != nil {
+			l.err = err
+			return true
+		}
+	}
+}
+
+func (l *lineSource) Decode(v any) error {
+
+	if !l.More() {
+		return io.EOF
+	}
+
+	if l.err != nil {
+		return l.err
+	}
+
+	line := l.line
+	l.line = nil
+	l.last = line
+	l.start = l.next
+	l.offset = l.read
+
+	return l.unmarshal(line, v)
+}
+
+// unmarshal decodes the line with the decoder of the previous lines, a decoder per line is much slower. The line should be a single value,
+// the decoder is dropped if it is not or if the line can not be decoded, as the rest of the line would be read with the next line.
+func (l *lineSource) unmarshal(line []byte, v any) error {
+
+	if l.dec == nil {
+		l.dec, l.fed = json.NewDecoder(&l.feed), 0
+		l.dec.UseNumber()
+	}
+
+	l.feed.line = line
+	err := l.dec.Decode(v)
+
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) { //counted from the start of the line, like with a decoder per line.
+		syntax.Offset -= l.fed
+	}
+
+	l.fed += int64(len(line))
+	if err != nil || l.dec.InputOffset() != l.fed || len(l.feed.line) > 0 {
+		l.dec, l.feed.line = nil, nil
+	}
+
+	return err
+}
+
+func (l *lineSource) InputOffset() int64 {
+	return l.offset
+}
+
+func (l *lineSource) Buffered() io.Reader {
+	return bytes.NewReader(l.line)
+}
+
+// toObjects continues newline delimited input as a stream of objects from the record which could not be decoded, if err is a syntax error.
+// The input mode is detected from the start of the input, so a record which is pretty printed or has comments later is read like in the
+// objects mode. The rest of the input goes through the converter and its offsets are mapped back to the input like in the objects mode.
+func (p *parser) toObjects(err error) bool {
+
+	l, ok := p.src.(*lineSource)
+	var syntax *json.SyntaxError
+	if !ok || !(errors.As(err, &syntax) || errors.Is(err, io.ErrUnexpectedEOF)) {
+		return false
+	}
+
+	p.logError(p.logger.Warn(), p.errorOffset(l.start, err), err, "record is not on a single line, the rest of the input is read as a stream of objects")
+
+	loc := p.locate(l.start)
+	input, tracker := converter.NewTracked(io.MultiReader(bytes.NewReader(l.rest), l.r), 0, converter.JSON, &p.logger)
+	tracker.Resume(loc.Offset, loc.Line, loc.Column)
+	p.locator = tracker
+
+	if p.rejects.mode == OnErrorSkip { //read again after a syntax error.
+		p.rejects.input = &countReader{r: input}
+		input = p.rejects.input
+	}
+
+	p.decoder = json.NewDecoder(input)
+	p.decoder.UseNumber()
+	p.src = p.decoder
+
+	return true
+}
+
+// lineFeed reads the line being decoded, the decoder gets io.EOF at its end.
+type lineFeed struct {
+	line []byte
+}
+
+func (f *lineFeed) Read(b []byte) (int, error) {
+
+	if len(f.line) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(b, f.line)
+	f.line = f.line[n:]
+	return n, nil
+}
@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"encoding/csv"
+	"sort"
+	"strings"
+)
+
+// table is a single output csv. Without normalize there is only the root table, in normalize mode every nested array of objects gets its own table.
+type table struct {
+	name    string              //Path of the nested array, empty for the root table.
+	out     *csv.Writer         //Writer of the table, created when the headers are written.
+	headers []string            //Headers of the table, set once the header row is written.
+	keys    map[string]struct{} //Keys seen while the headers are being discovered.
+	paths   map[string][]string //Columns which are not keys of the rows, their values are looked up by path.
+	ready   bool                //True once the header row is written.
+}
+
+// row is a single csv row and the table it belongs to.
+type row struct {
+	table  string
+	values map[string]any
+}
+
+// table returns the table by name, creating it on first use.
+func (p *parser) table(name string) *table {
+
+	t, ok := p.tables[name]
+	if ok {
+		return t
+	}
+
+	t = &table{name: name, keys: map[string]struct{}{}, paths: map[string][]string{}}
+	p.tables[name] = t
+	p.tableOrder = append(p.tableOrder, t)
+
+	if name != "" {
+		p.logger.Debug().Str("table", name).Msg("found new table")
+	}
+
+	return t
+}
+
+// addKeys adds the keys of the row to the headers of its table, if the headers are not written yet.
+func (p *parser) addKeys(r row) {
+
+	t := p.table(r.table)
+	if t.ready {
+		return
+	}
+
+	for key := range r.values {
+		t.keys[key] = struct{}{}
+	}
+}
+
+// writeHeaders freezes the headers of the table with the keys seen till now and writes the header row.
+func (p *parser) writeHeaders(t *table) {
+
+	t.headers = p.orderHeaders(t)
+	t.keys = nil
+	t.ready = true
+
+	p.project(t)
+
+	if t.name == "" {
+		t.out = p.out
+	} else {
+		t.out = p.tableWriter(t.name)
+	}
+
+	if p.existing != nil {
+		existing, err := p.existing(t.name)
+		if err != nil {
+			p.logger.Fatal().Err(err).Msg("error while reading output file")
+		}
+		if existing != nil { //appended to a file which has a header row.
+			if strings.Join(existing, "\x00") != strings.Join(t.headers, "\x00") {
+				p.logger.Fatal().Strs("file", existing).Strs("headers", t.headers).Msg("the headers are not the same as the headers of the output file")
+			}
+			return
+		}
+	}
+
+	headerMap := make(map[string]any, len(t.headers))
+	for _, header := range t.headers {
+		headerMap[header] = header //We are using map because we want to write this as first row itself and our writeRow method only takes map.
+	}
+
+	p.writeRow(t, headerMap, true)
+}
+
+// SetAppend sets the function which returns the header row of the output file of a table which is appended to, nil if the file is empty.
+// The header row is not written again to such files and the headers should be the same.
+func (p *parser) SetAppend(existing func(table string) ([]string, error)) *parser {
+	p.existing = existing
+	return p
+}
+
+// tableWriter creates the writer of a child table.
+func (p *parser) tableWriter(name string) *csv.Writer {
+
+	w, err := p.newWriter(name)
+	if err != nil {
+		p.logger.Fatal().Err(err).Msgf("error while creating output file of table %s", name)
+	}
+
+	return w
+}
+
+// write writes the rows to their tables. Tables which do not have headers yet get them from the rows being written.
+func (p *parser) write(rows []row) {
+
+	for _, r := range rows {
+		p.addKeys(r)
+	}
+
+	for _, r := range rows {
+		t := p.table(r.table)
+		if !t.ready {
+			p.writeHeaders(t)
+		}
+		p.writeRow(t, r.values, false)
+	}
+}
+
+func (p *parser) flush() {
+	for _, t := range p.tableOrder {
+		if t.out != nil {
+			t.out.Flush()
+		}
+	}
+	if p.rejects.out != nil {
+		p.rejects.out.Flush()
+	}
+}
+
+// sortHeaders returns the keys sorted. The generated columns of normalize mode always come first.
+func sortHeaders(keys map[string]struct{}) []string {
+
+	headers := make([]string, 0, len(keys))
+
+	for _, key := range generatedColumns {
+		if _, ok := keys[key]; ok {
+			headers = append(headers, key)
+		}
+	}
+
+	generated := len(headers)
+
+	for key := range keys {
+		if !isGenerated(key) {
+			headers = append(headers, key)
+		}
+	}
+
+	sort.Strings(headers[generated:]) //Sort headers or we will get random order every run because maps & json being unordered.
+
+	return headers
+}
@@ -0,0 +1,226 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Presets of the time format.
+const (
+	FormatRFC3339     = "rfc3339"
+	FormatRFC3339Nano = "rfc3339nano"
+	FormatISODate     = "iso-date"
+	FormatISODateTime = "iso-datetime"
+	FormatExcelSerial = "excel-serial" //days since 1899-12-30, with the time as fraction of the day.
+)
+
+var timePresets = map[string]string{
+	FormatRFC3339:     time.RFC3339,
+	FormatRFC3339Nano: time.RFC3339Nano,
+	FormatISODate:     "2006-01-02",
+	FormatISODateTime: "2006-01-02T15:04:05",
+}
+
+// strftime directives and their Go layouts. %f and %L are the micro and milli seconds, they need a dot before them like %S.%L.
+var strftime = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'j': "002",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM",
+	'b': "Jan", 'h': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+	'z': "-0700", 'Z': "MST", 'f': "000000", 'L': "000",
+	'F': "2006-01-02", 'T': "15:04:05", 'D': "01/02/06", '%': "%",
+}
+
+// LayoutAuto is the input layout which tries every layout of autoLayouts.
+const LayoutAuto = "auto"
+
+// autoLayouts are the common date formats which can be read without knowing the layout. Day and month orders like 02/01/2023 are
+// ambiguous and are not part of it. Fractional seconds are accepted after the seconds by every layout.
+var autoLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.RFC822Z,
+	time.RFC822,
+	time.ANSIC,
+}
+
+// excelEpoch is day 0 of the excel serial dates.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// timeFormat is how the converted timestamps are written.
+type timeFormat struct {
+	layout string         //Go layout of the output.
+	excel  bool           //write excel serial dates instead of the layout.
+	loc    *time.Location //zone of the output, UTC by default so that the output does not depend on the host.
+}
+
+// SetTimeFormat sets the layout and zone of the converted timestamps. format is a preset (rfc3339, rfc3339nano, iso-date, iso-datetime, excel-serial),
+// a strftime format like %Y-%m-%d or a Go layout like 2006-01-02. tz is a zone name like Asia/Kolkata, UTC, Local or an offset like +05:30.
+func (p *parser) SetTimeFormat(format, tz string) *parser {
+
+	tf, err := newTimeFormat(format, tz)
+	if err != nil {
+		p.logger.Fatal().Err(err).Msg("invalid time format")
+	}
+
+	p.times = tf
+	return p
+}
+
+// SetInputLayouts sets the layouts of the date strings in the timestamp columns. Every layout can be a preset, a strftime format or a Go layout
+// like the time format, auto adds the common formats. Strings without a zone are read in the zone of the time format.
+func (p *parser) SetInputLayouts(layouts []string) *parser {
+
+	p.layouts = nil
+
+	for _, layout := range layouts {
+
+		if layout == LayoutAuto {
+			p.layouts = append(p.layouts, autoLayouts...)
+			continue
+		}
+
+		if layout == FormatExcelSerial {
+			p.logger.Fatal().Msgf("%s can not be used as an input layout", layout)
+		}
+
+		parsed, err := parseLayout(layout)
+		if err != nil {
+			p.logger.Fatal().Err(err).Msg("invalid input layout")
+		}
+		p.layouts = append(p.layouts, parsed)
+	}
+
+	return p
+}
+
+// parseTime reads the date string with the first input layout which matches.
+func (p *parser) parseTime(text string) (time.Time, bool) {
+	return parseIn(p.layouts, text, p.times.loc)
+}
+
+// parseIn reads the date string with the first layout which matches. Strings without a zone are read in loc.
+func parseIn(layouts []string, text string, loc *time.Location) (time.Time, bool) {
+
+	text = strings.TrimSpace(text)
+
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, text, loc); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func newTimeFormat(format, tz string) (timeFormat, error) {
+
+	loc, err := loadLocation(tz)
+	if err != nil {
+		return timeFormat{}, err
+	}
+
+	layout, err := parseLayout(format)
+	if err != nil {
+		return timeFormat{}, err
+	}
+
+	return timeFormat{layout: layout, excel: format == FormatExcelSerial, loc: loc}, nil
+}
+
+// parseLayout returns the Go layout of the preset, strftime format or Go layout. Empty format is rfc3339.
+func parseLayout(format string) (string, error) {
+
+	if format == "" {
+		format = FormatRFC3339
+	}
+
+	if layout, ok := timePresets[format]; ok {
+		return layout, nil
+	}
+
+	if format == FormatExcelSerial {
+		return "", nil
+	}
+
+	if !strings.Contains(format, "%") {
+		return format, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+
+		if i+1 >= len(format) {
+			return "", fmt.Errorf("format %q ends with %%", format)
+		}
+
+		i++
+		layout, ok := strftime[format[i]]
+		if !ok {
+			return "", fmt.Errorf("unknown directive %%%c in format %q", format[i], format)
+		}
+		b.WriteString(layout)
+	}
+
+	return b.String(), nil
+}
+
+// loadLocation loads the zone by name, or creates a fixed zone for offsets like +05:30. Empty zone is UTC.
+func loadLocation(tz string) (*time.Location, error) {
+
+	if tz == "" {
+		return time.UTC, nil
+	}
+
+	if (tz[0] == '+' || tz[0] == '-') && len(tz) >= 3 {
+		t, err := time.Parse("-07:00", tz)
+		if err != nil {
+			t, err = time.Parse("-0700", tz)
+		}
+		if err != nil {
+			t, err = time.Parse("-07", tz)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset %q, usage +05:30", tz)
+		}
+		_, offset := t.Zone()
+		return time.FixedZone(tz, offset), nil
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q : %w", tz, err)
+	}
+
+	return loc, nil
+}
+
+// format writes the time in the zone and layout of the time format.
+func (tf timeFormat) format(t time.Time) string {
+
+	t = t.In(tf.loc)
+
+	if !tf.excel {
+		return t.Format(tf.layout)
+	}
+
+	//Excel has no zones, the serial is the wall clock of the zone.
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	seconds := float64(wall.Unix()-excelEpoch.Unix()) + float64(wall.Nanosecond())/float64(time.Second)
+
+	return strconv.FormatFloat(seconds/(24*60*60), 'f', -1, 64)
+}
@@ -0,0 +1,293 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/akshaykhairmode/j2csv/expr"
+)
+
+// transform is the pipeline of steps applied to the values of the matching columns.
+type transform struct {
+	glob     *regexp.Regexp
+	steps    []func(string) string
+	fallback *string //text of the default step, written for missing values.
+}
+
+// SetTransform sets the transforms of the columns. Every transform is column=step|step, like name=trim|upper or price=scale(0.01)|round(2).
+// The column can be a glob. The steps are applied in order to the text of the value, after the timestamps and numbers are formatted.
+func (p *parser) SetTransform(transforms []string) *parser {
+
+	p.transforms = nil
+	p.transformed = map[string][]func(string) string{}
+	p.missing = map[string]string{}
+
+	for _, t := range transforms {
+
+		column, src, ok := strings.Cut(t, "=")
+		column = strings.TrimSpace(column)
+		if !ok || column == "" {
+			p.logger.Fatal().Msgf("invalid transform %q, usage --transform 'name=trim|upper'", t)
+		}
+
+		calls, err := expr.ParsePipeline(src)
+		if err != nil {
+			p.logger.Fatal().Msgf("invalid transform of %s : %v", column, err)
+		}
+
+		tr := transform{glob: compileGlob(column)}
+		for _, call := range calls {
+			step, err := newStep(call)
+			if err != nil {
+				p.logger.Fatal().Msgf("invalid transform of %s : %v", column, &expr.Error{Expr: src, Pos: call.Pos, Msg: err.Error()})
+			}
+			tr.steps = append(tr.steps, step)
+			if call.Name == "default" {
+				fallback := expr.String(call.Args[0])
+				tr.fallback = &fallback
+			}
+		}
+
+		p.transforms = append(p.transforms, tr)
+	}
+
+	return p
+}
+
+// transformValue applies the steps of every transform matching the column to the value.
+func (p *parser) transformValue(header, value string) string {
+
+	if len(p.transforms) <= 0 {
+		return value
+	}
+
+	steps, ok := p.transformed[header]
+	if !ok { //the matching steps are cached for every column.
+		for _, t := range p.transforms {
+			if t.glob.MatchString(header) {
+				steps = append(steps, t.steps...)
+			}
+		}
+		p.transformed[header] = steps
+	}
+
+	for _, step := range steps {
+		value = step(value)
+	}
+
+	return value
+}
+
+// missingValue returns the text of a missing value of the column. It is the text of the default step of the column if it has one,
+// else the placeholder as it is, the other steps are only applied to the values of the record.
+func (p *parser) missingValue(header string) string {
+
+	value, ok := p.missing[header]
+	if ok {
+		return value
+	}
+
+	value = p.defaults
+	for _, t := range p.transforms {
+		if t.fallback != nil && t.glob.MatchString(header) {
+			value = *t.fallback
+		}
+	}
+
+	if p.missing != nil { //cached like the steps, there is no cache without transforms.
+		p.missing[header] = value
+	}
+
+	return value
+}
+
+// newStep returns the function of the step.
+func newStep(call expr.Call) (func(string) string, error) {
+
+	args := call.Args
+
+	switch call.Name {
+	case "trim":
+		return strings.TrimSpace, checkArgs(call, 0, 0)
+	case "upper":
+		return strings.ToUpper, checkArgs(call, 0, 0)
+	case "lower":
+		return strings.ToLower, checkArgs(call, 0, 0)
+	case "replace": //replace(regexp, replacement), the replacement can use $1 for the groups.
+		if err := checkArgs(call, 2, 2); err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(expr.String(args[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp : %v", err)
+		}
+		replacement := expr.String(args[1])
+		return func(s string) string { return re.ReplaceAllString(s, replacement) }, nil
+	case "substr": //substr(start, length), negative start counts from the end.
+		if err := checkArgs(call, 1, 2); err != nil {
+			return nil, err
+		}
+		start, err := intArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		length := -1
+		if len(args) > 1 {
+			if length, err = intArg(args[1]); err != nil || length < 0 {
+				return nil, fmt.Errorf("length should be a positive number")
+			}
+		}
+		return func(s string) string { return substr(s, start, length) }, nil
+	case "pad", "padRight": //pad(width, char) pads on the left, padRight on the right. The default char is a space.
+		if err := checkArgs(call, 1, 2); err != nil {
+			return nil, err
+		}
+		width, err := intArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		char := " "
+		if len(args) > 1 {
+			char = expr.String(args[1])
+		}
+		if utf8.RuneCountInString(char) != 1 {
+			return nil, fmt.Errorf("pad char should be one character, got %q", char)
+		}
+		left := call.Name == "pad"
+		return func(s string) string {
+			n := width - utf8.RuneCountInString(s)
+			if n <= 0 {
+				return s
+			}
+			if left {
+				return strings.Repeat(char, n) + s
+			}
+			return s + strings.Repeat(char, n)
+		}, nil
+	case "scale": //scale(0.01) multiplies numbers exactly, cents to dollars.
+		if err := checkArgs(call, 1, 1); err != nil {
+			return nil, err
+		}
+		factor, ok := new(big.Rat).SetString(expr.String(args[0]))
+		if _, isNumber := args[0].(json.Number); !ok || !isNumber {
+			return nil, fmt.Errorf("scale needs a number, usage scale(0.01)")
+		}
+		return func(s string) string {
+			r, ok := new(big.Rat).SetString(s)
+			if !ok {
+				return s
+			}
+			return expr.String(r.Mul(r, factor))
+		}, nil
+	case "round": //round(decimals), half away from zero.
+		if err := checkArgs(call, 1, 1); err != nil {
+			return nil, err
+		}
+		decimals, err := intArg(args[0])
+		if err != nil || decimals < 0 {
+			return nil, fmt.Errorf("decimals should be a positive number")
+		}
+		return func(s string) string {
+			d, ok := parseDecimal(s)
+			if !ok || d.exp > maxExponent || d.exp < -maxExponent {
+				return s
+			}
+			return d.round(-decimals).plain(decimals, "")
+		}, nil
+	case "map": //map("M", "Male", "F", "Female", "Unknown"), the last argument of an odd list is the default, other values are kept.
+		if err := checkArgs(call, 2, -1); err != nil {
+			return nil, err
+		}
+		table := map[string]string{}
+		for i := 0; i+1 < len(args); i += 2 {
+			table[expr.String(args[i])] = expr.String(args[i+1])
+		}
+		fallback, hasFallback := "", len(args)%2 == 1
+		if hasFallback {
+			fallback = expr.String(args[len(args)-1])
+		}
+		return func(s string) string {
+			if v, ok := table[s]; ok {
+				return v
+			}
+			if hasFallback {
+				return fallback
+			}
+			return s
+		}, nil
+	case "bool": //bool("Y", "N") writes true and false as Y and N.
+		if err := checkArgs(call, 2, 2); err != nil {
+			return nil, err
+		}
+		yes, no := expr.String(args[0]), expr.String(args[1])
+		return func(s string) string {
+			switch s {
+			case "true":
+				return yes
+			case "false":
+				return no
+			}
+			return s
+		}, nil
+	case "default": //default("NA") writes NA for empty values.
+		if err := checkArgs(call, 1, 1); err != nil {
+			return nil, err
+		}
+		fallback := expr.String(args[0])
+		return func(s string) string {
+			if s == "" {
+				return fallback
+			}
+			return s
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown transform %s, allowed values are trim, upper, lower, replace, substr, pad, padRight, scale, round, map, bool and default", call.Name)
+}
+
+func checkArgs(call expr.Call, min, max int) error {
+	if len(call.Args) < min || (max >= 0 && len(call.Args) > max) {
+		return fmt.Errorf("wrong number of arguments for %s, got %d", call.Name, len(call.Args))
+	}
+	return nil
+}
+
+func intArg(arg any) (int, error) {
+	n, ok := arg.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("expected a whole number, got %v", arg)
+	}
+	i, err := strconv.Atoi(n.String())
+	if err != nil {
+		return 0, fmt.Errorf("expected a whole number, got %v", n)
+	}
+	return i, nil
+}
+
+// substr returns length runes of s from start. Negative start counts from the end, negative length takes the rest.
+func substr(s string, start, length int) string {
+
+	runes := []rune(s)
+
+	if start < 0 {
+		start += len(runes)
+		if start < 0 {
+			start = 0
+		}
+	}
+	if start > len(runes) {
+		return ""
+	}
+
+	end := len(runes)
+	if length >= 0 && start+length < end {
+		end = start + length
+	}
+
+	return string(runes[start:end])
+}
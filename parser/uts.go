@@ -3,11 +3,26 @@ package parser
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// Units of the unix timestamps.
+const (
+	UnitSeconds = "s"
+	UnitMillis  = "ms"
+	UnitMicros  = "us"
+	UnitNanos   = "ns"
+	UnitAuto    = "auto" //the unit is inferred from the magnitude of the value.
+)
+
+// unitShift is the power of 10 which converts the unit to nanoseconds.
+var unitShift = map[string]int{UnitSeconds: 9, UnitMillis: 6, UnitMicros: 3, UnitNanos: 0}
+
+// setUTS sets the columns to convert from unix timestamps. Every column can have a unit, like createdAt:ms, seconds is the default.
 func (p *parser) setUTS(uts string, headerMap map[string]any) {
 
 	trimmed := strings.TrimSpace(uts)
@@ -22,37 +37,110 @@ func (p *parser) setUTS(uts string, headerMap map[string]any) {
 	}
 
 	for _, field := range fields {
+
+		field, unit, _ := strings.Cut(strings.TrimSpace(field), ":")
+		if unit == "" {
+			unit = UnitSeconds
+		}
+
+		if _, ok := unitShift[unit]; !ok && unit != UnitAuto {
+			p.logger.Fatal().Msgf("unknown unit %q for %v, allowed values are s, ms, us, ns and auto", unit, field)
+		}
+
 		if _, ok := headerMap[field]; !ok {
-			p.logger.Fatal().Msgf("Passed header %v does not match with file headers : %v", field, p.headers)
+			headers := make([]string, 0, len(headerMap))
+			for header := range headerMap {
+				headers = append(headers, header)
+			}
+			sort.Strings(headers)
+			p.logger.Fatal().Msgf("Passed header %v does not match with file headers : %v", field, headers)
 		}
-		p.utsHeaders[field] = struct{}{}
+		p.utsHeaders[field] = unit
+	}
+}
+
+// epoch converts the unix timestamp text in the unit to time. Fractions and exponents are converted exactly, 1672325049.5 is half a second past.
+func epoch(text, unit string) (time.Time, bool) {
+
+	d, ok := parseDecimal(strings.TrimSpace(text))
+	if !ok || d.exp > 30 {
+		return time.Time{}, false
+	}
+
+	if unit == UnitAuto {
+		unit = inferUnit(d)
+	}
+
+	d.exp += unitShift[unit]
+	d = d.round(0) //whole nanoseconds.
+
+	nanos, ok := new(big.Int).SetString(d.digits+strings.Repeat("0", d.exp), 10)
+	if !ok {
+		nanos = new(big.Int)
+	}
+	if d.neg {
+		nanos.Neg(nanos)
 	}
+
+	sec, nsec := new(big.Int).QuoRem(nanos, big.NewInt(int64(time.Second)), new(big.Int))
+	if !sec.IsInt64() {
+		return time.Time{}, false
+	}
+
+	return time.Unix(sec.Int64(), nsec.Int64()), true
+}
+
+// inferUnit infers the unit from the number of digits of the integer part. Seconds have up to 11 digits till the year 5000,
+// milliseconds up to 14, microseconds up to 17 and anything bigger is nanoseconds.
+func inferUnit(d decimal) string {
+
+	digits := len(d.digits) + d.exp
+
+	switch {
+	case digits <= 11:
+		return UnitSeconds
+	case digits <= 14:
+		return UnitMillis
+	case digits <= 17:
+		return UnitMicros
+	}
+
+	return UnitNanos
 }
 
 func (p *parser) parseRowValue(header string, value any) string {
 
-	_, isUTSColumn := p.utsHeaders[header] //check if the column exist in
+	unit, isUTSColumn := p.utsHeaders[header] //check if the column exist in
 	switch v := value.(type) {
-	case float64: //json decodes numbers as float64.
+	case json.Number: //numbers are decoded as json.Number, the text of the input is kept.
+		if isUTSColumn {
+			if t, ok := epoch(v.String(), unit); ok {
+				return p.times.format(t)
+			}
+			p.logger.Debug().Str("number", v.String()).Msg("could not convert the number to a timestamp")
+		}
+		return p.numbers.formatNumber(v.String())
+	case float64:
+		text := strconv.FormatFloat(v, 'f', -1, 64)
 		if isUTSColumn {
-			t := time.Unix(int64(v), 0)
-			return t.String()
+			if t, ok := epoch(text, unit); ok {
+				return p.times.format(t)
+			}
 		}
-		return strconv.FormatFloat(v, 'f', -1, 64)
+		return p.numbers.formatNumber(text)
 	case string:
 		if isUTSColumn {
-			val, err := strconv.ParseInt(v, 10, 64) //first convert to int
-			if err != nil {
-				p.logger.Debug().Str("str", v).Msg("could not convert the string to int64")
-				return v
+			t, ok := epoch(v, unit) //numeric strings are converted and written the same way as numbers.
+			if !ok {
+				t, ok = p.parseTime(v) //other strings are read with the input layouts.
 			}
-			text, err := time.Unix(val, 0).Local().MarshalText()
-			if err == nil {
-				return string(text)
+			if !ok {
+				p.logger.Debug().Str("str", v).Msg("could not convert the string to a timestamp")
+				return v
 			}
-			return v
+			return p.times.format(t)
 		}
-	case map[string]any: //If its nested JSON, marshal it and return the string
+	case map[string]any, []any: //If its nested JSON or an array, marshal it and return the string
 		nested, err := json.Marshal(v)
 		if err != nil {
 			p.logger.Debug().Err(err).Msg("error while marshaling nested JSON")
@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"github.com/akshaykhairmode/j2csv/expr"
+)
+
+// SetWhere sets the expression which filters the records, like status == "active" && age >= 18. Only records where it is true are written.
+// The expression is evaluated on the decoded record before it is exploded or flattened, so paths like address.zip work without -flatten.
+func (p *parser) SetWhere(where string) *parser {
+
+	if where == "" {
+		p.where = nil
+		return p
+	}
+
+	e, err := expr.Compile(where, p.exprFuncs())
+	if err != nil {
+		p.logger.Fatal().Msgf("invalid where expression : %v", err)
+	}
+
+	p.where = e
+	return p
+}